@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"kube-kg/internal/api"
 	"kube-kg/internal/config"
@@ -18,74 +19,135 @@ import (
 )
 
 func main() {
-	// Setup structured logging
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	slog.SetDefault(logger)
-
 	// Create a main context that can be cancelled to signal shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Load configuration
-	cfg := config.LoadConfig()
-	slog.Info("Configuration loaded successfully")
+	// Load configuration from the config file, the environment and the command line
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.WriteYAML(os.Stdout); err != nil {
+			slog.ErrorContext(ctx, "failed to print configuration", "error", err)
+			os.Exit(1)
+		}
+		if err := cfg.Validate(); err != nil {
+			slog.ErrorContext(ctx, "configuration is invalid", "error", err)
+			os.Exit(2)
+		}
+		os.Exit(0)
+	}
+
+	// Setup structured logging. The level can be changed at runtime through the API.
+	var logLevel slog.LevelVar
+	if err := logLevel.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		slog.WarnContext(ctx, "ignoring invalid log level", "level", cfg.LogLevel, "err", err)
+	}
+	logger := slog.New(observability.NewLogHandler(os.Stdout, cfg.LogFormat, &logLevel))
+	slog.SetDefault(logger)
+	if err := cfg.Validate(); err != nil {
+		slog.ErrorContext(ctx, "configuration is invalid", "error", err)
+		os.Exit(2)
+	}
+	slog.InfoContext(ctx, "Configuration loaded successfully")
 
 	// Initialize OpenTelemetry
-	tracerProvider, err := observability.InitTracerProvider(ctx, cfg.OtelExporterEndpoint)
+	tracerProvider, err := observability.InitTracerProvider(ctx, cfg)
 	if err != nil {
-		slog.Error("failed to initialize tracer provider", "error", err)
+		slog.ErrorContext(ctx, "failed to initialize tracer provider", "error", err)
 		os.Exit(1)
 	}
 	defer func() {
 		// Use a background context for shutdown to ensure it completes
 		if err := tracerProvider.Shutdown(context.Background()); err != nil {
-			slog.Error("failed to shutdown tracer provider", "error", err)
+			slog.ErrorContext(ctx, "failed to shutdown tracer provider", "error", err)
+		}
+	}()
+
+	meterProvider, metricsHandler, err := observability.InitMeterProvider(ctx, cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to initialize meter provider", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := meterProvider.Shutdown(context.Background()); err != nil {
+			slog.ErrorContext(ctx, "failed to shutdown meter provider", "error", err)
 		}
 	}()
 
 	// Initialize clients
 	neo4jClient, err := neo4j.NewClient(ctx, cfg)
 	if err != nil {
-		slog.Error("failed to initialize Neo4j client", "error", err, "url", cfg.Neo4jURI)
+		slog.ErrorContext(ctx, "failed to initialize Neo4j client", "error", err, "url", cfg.Neo4jURI)
 		os.Exit(1)
 	}
 
-	if cfg.KubeviewURL == "" {
-		slog.Error("failed to initialize Kubeview client", "url", cfg.KubeviewURL)
-		os.Exit(2)
+	retryPolicy := kubeview.RetryPolicy{
+		MaxAttempts:  cfg.KubeviewRetryMaxAttempts,
+		InitialDelay: cfg.KubeviewRetryInitialDelay,
+		MaxDelay:     cfg.KubeviewRetryMaxDelay,
+		Multiplier:   cfg.KubeviewRetryMultiplier,
+		Jitter:       cfg.KubeviewRetryJitter,
 	}
-	kubeviewClient := kubeview.NewClient(cfg.KubeviewURL)
+	kubeviewClient := kubeview.NewClient(cfg.KubeviewURL,
+		kubeview.WithTimeout(cfg.KubeviewTimeout),
+		kubeview.WithRetryPolicy(retryPolicy),
+		kubeview.WithCircuitBreaker(kubeview.NewCircuitBreaker(cfg.KubeviewBreakerThreshold, cfg.KubeviewBreakerCooldown)),
+	)
 
 	// Initialize the processor
-	proc := processor.NewProcessor(kubeviewClient, neo4jClient)
-
-	// Start initial synchronization in a background goroutine
-	go func() {
-		slog.Info("Starting initial cluster synchronization")
-		if err := proc.InitialSync(ctx); err != nil {
-			slog.Error("initial sync failed", "error", err)
-		} else {
-			slog.Info("Initial cluster synchronization completed successfully")
-		}
-	}()
+	namespaceRetryPolicy := retryPolicy
+	namespaceRetryPolicy.MaxAttempts = cfg.SyncNamespaceMaxAttempts
+	proc := processor.NewProcessor(kubeviewClient, neo4jClient,
+		processor.WithNamespaceRetryPolicy(namespaceRetryPolicy),
+		processor.WithEventWorkers(cfg.EventWorkers, cfg.EventQueueSize),
+		processor.WithCoalescingWindow(cfg.EventCoalesceWindow),
+		processor.WithReplayBuffer(cfg.EventReplayBufferSize),
+		processor.WithSyncConcurrency(cfg.SyncFetchConcurrency, cfg.SyncWriteConcurrency),
+		processor.WithPeriodicResync(cfg.ResyncInterval, processor.ResyncMode(cfg.ResyncMode)),
+		processor.WithNamespaceFilter(processor.NamespaceFilter{
+			Include: cfg.IncludeNamespaces,
+			Exclude: cfg.ExcludeNamespaces,
+		}))
+
+	// Start initial synchronization in a background goroutine. Events received until it
+	// completes are buffered by the processor and replayed afterwards.
+	proc.StartInitialSync(ctx)
 
 	// Create channel for KubeView events and start the event processor
-	eventChan := make(chan kubeview.Event)
+	eventChan := make(chan kubeview.Event, cfg.EventQueueSize)
 	kubeviewClient.StreamUpdates(ctx, cfg.ClientID, eventChan)
-	processor.StartEventProcessor(ctx, eventChan, neo4jClient)
-	slog.Info("Started real-time event processor")
+	proc.StartEventProcessor(ctx, eventChan)
+	proc.WatchStreamGaps(ctx, kubeviewClient.Gaps())
+	proc.StartResyncScheduler(ctx)
+	slog.InfoContext(ctx, "Started real-time event processor")
+
+	// Reload the configuration on SIGHUP and through the API
+	configReloader := newReloader(cfg, &logLevel, neo4jClient, kubeviewClient, proc)
+	configReloader.start(ctx)
 
 	// Setup and start HTTP server
-	server := api.NewServer(kubeviewClient, neo4jClient, proc)
+	server := api.NewServer(kubeviewClient, neo4jClient, proc,
+		api.WithProbeTTL(cfg.ReadinessCacheTTL),
+		api.WithStallTimeout(cfg.LivenessStallTimeout),
+		api.WithMetricsHandler(metricsHandler),
+		api.WithLogLevel(&logLevel),
+		api.WithConfigReloader(configReloader))
 	httpServer := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.ListenAddress,
 		Handler: server,
 	}
 
 	go func() {
-		slog.Info("Starting HTTP server", "address", httpServer.Addr)
+		slog.InfoContext(ctx, "Starting HTTP server", "address", httpServer.Addr)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("HTTP server failed", "error", err)
+			slog.ErrorContext(ctx, "HTTP server failed", "error", err)
 			os.Exit(1)
 		}
 	}()
@@ -96,24 +158,34 @@ func main() {
 	<-quit
 
 	// Initiate graceful shutdown
-	slog.Info("Shutting down server...")
+	slog.InfoContext(ctx, "Shutting down server...")
 
 	// Create a context with a timeout for shutdown
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer shutdownCancel()
 
 	// Shutdown HTTP server
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
-		slog.Error("HTTP server shutdown failed", "error", err)
+		slog.ErrorContext(ctx, "HTTP server shutdown failed", "error", err)
 	}
 
-	// Close Neo4j client connection
-	if err := neo4jClient.Close(shutdownCtx); err != nil {
-		slog.Error("Failed to close Neo4j client", "error", err)
+	// Cancel a running refresh job
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.ErrorContext(ctx, "Refresh job did not stop", "error", err)
 	}
 
 	// Cancel the main context to signal background processes to stop
 	cancel()
 
-	slog.Info("Server gracefully stopped")
+	// Let the event workers apply the events already received
+	if err := proc.Drain(shutdownCtx); err != nil {
+		slog.ErrorContext(ctx, "Event processor did not drain", "error", err)
+	}
+
+	// Close Neo4j client connection
+	if err := neo4jClient.Close(shutdownCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to close Neo4j client", "error", err)
+	}
+
+	slog.InfoContext(ctx, "Server gracefully stopped")
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+
+	"kube-kg/internal/config"
+	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+	"kube-kg/internal/processor"
+)
+
+// reloader applies a reloaded configuration to the running service.
+type reloader struct {
+	mu sync.Mutex
+	// current is the configuration in effect.
+	current        *config.Config
+	logLevel       *slog.LevelVar
+	neo4jClient    *neo4j.Client
+	kubeviewClient *kubeview.Client
+	processor      *processor.Processor
+	// resyncs requests a full resync, which a reload of the Neo4j URI needs as the graph may be empty.
+	resyncs chan struct{}
+}
+
+func newReloader(cfg *config.Config, logLevel *slog.LevelVar, neo4jClient *neo4j.Client,
+	kubeviewClient *kubeview.Client, proc *processor.Processor) *reloader {
+	return &reloader{
+		current:        cfg,
+		logLevel:       logLevel,
+		neo4jClient:    neo4jClient,
+		kubeviewClient: kubeviewClient,
+		processor:      proc,
+		resyncs:        make(chan struct{}, 1),
+	}
+}
+
+// Reload reads the configuration again and, if it is valid, applies the settings that can change at
+// runtime. Nothing is applied if Neo4j cannot be reached with the new settings. A new Neo4j URI may point
+// to another database, so the whole cluster is synchronized again. Every change is logged, with secrets
+// redacted.
+func (r *reloader) Reload(ctx context.Context) ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.current.Reload()
+	if err != nil {
+		return nil, &config.ValidationError{Problems: []string{err.Error()}}
+	}
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	changes := r.current.Diff(next)
+	changed := func(settings ...string) bool {
+		return slices.ContainsFunc(changes, func(change config.Change) bool {
+			return slices.Contains(settings, change.Setting)
+		})
+	}
+
+	if changed("neo4j_uri", "neo4j_user", "neo4j_password", "neo4j_max_connection_pool_size") {
+		if err := r.neo4jClient.Reconnect(ctx, next); err != nil {
+			return nil, fmt.Errorf("failed to reconnect to Neo4j: %w", err)
+		}
+	}
+	if changed("neo4j_uri") {
+		select {
+		case r.resyncs <- struct{}{}:
+		default:
+			// A resync is already pending.
+		}
+	}
+	if changed("kubeview_url", "client_id") {
+		r.kubeviewClient.SetEndpoint(ctx, next.KubeviewURL, next.ClientID)
+	}
+	if changed("include_namespaces", "exclude_namespaces") {
+		r.processor.SetNamespaceFilter(processor.NamespaceFilter{
+			Include: next.IncludeNamespaces,
+			Exclude: next.ExcludeNamespaces,
+		})
+	}
+	if changed("log_level") {
+		if err := r.logLevel.UnmarshalText([]byte(next.LogLevel)); err != nil {
+			return nil, fmt.Errorf("failed to set log level: %w", err)
+		}
+	}
+
+	for _, change := range changes {
+		if change.Reloadable {
+			slog.InfoContext(ctx, "configuration changed", "setting", change.Setting, "old", change.Old,
+				"new", change.New)
+		} else {
+			slog.WarnContext(ctx, "configuration change requires a restart", "setting", change.Setting,
+				"old", change.Old, "new", change.New)
+		}
+	}
+	slog.InfoContext(ctx, "configuration reloaded", "changes", len(changes))
+	r.current = r.current.Reloaded(next)
+	return changes, nil
+}
+
+// start reloads the configuration whenever the process receives SIGHUP, and runs the resyncs that reloads
+// request, until ctx is cancelled.
+func (r *reloader) start(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				if _, err := r.Reload(ctx); err != nil {
+					slog.ErrorContext(ctx, "failed to reload configuration", "error", err)
+				}
+			}
+		}
+	}()
+
+	// Resyncs run on their own so that a long one does not hold up further reloads.
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.resyncs:
+				slog.InfoContext(ctx, "resyncing the cluster into the new Neo4j database")
+				if err := r.processor.Refresh(ctx, processor.Scope{}, nil); err != nil {
+					slog.ErrorContext(ctx, "resync after reconnecting to Neo4j failed", "error", err)
+				}
+			}
+		}
+	}()
+}
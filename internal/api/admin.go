@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"kube-kg/internal/config"
+)
+
+// LogLevel is the body of the /admin/log-level endpoint.
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// ConfigReload is the response of the /config/reload endpoint.
+type ConfigReload struct {
+	// Changes lists the settings that changed, including those that only take effect after a restart.
+	Changes []config.Change `json:"changes"`
+}
+
+// ConfigReloadError is the response of the /config/reload endpoint when the configuration is invalid.
+type ConfigReloadError struct {
+	Error    string   `json:"error"`
+	Problems []string `json:"problems"`
+}
+
+// handleGetLogLevel returns the minimum level of the records logged.
+func (s *Server) handleGetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, LogLevel{Level: strings.ToLower(s.logLevel.Level().String())})
+	}
+}
+
+// handleSetLogLevel changes the minimum level of the records logged until the service restarts.
+func (s *Server) handleSetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid log level: " + err.Error()})
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		previous := s.logLevel.Level()
+		s.logLevel.Set(level)
+		slog.InfoContext(r.Context(), "log level changed", "from", previous, "to", level)
+		writeJSON(w, http.StatusOK, LogLevel{Level: strings.ToLower(level.String())})
+	}
+}
+
+// handleConfigReload reloads the configuration. An invalid configuration is rejected with every problem
+// found, leaving the running configuration unchanged.
+func (s *Server) handleConfigReload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changes, err := s.configReloader.Reload(r.Context())
+		var invalid *config.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeJSON(w, http.StatusBadRequest, ConfigReloadError{Error: err.Error(), Problems: invalid.Problems})
+			return
+		case err != nil:
+			slog.ErrorContext(r.Context(), "failed to reload configuration", "err", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if changes == nil {
+			changes = []config.Change{}
+		}
+		writeJSON(w, http.StatusOK, ConfigReload{Changes: changes})
+	}
+}
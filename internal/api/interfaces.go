@@ -2,21 +2,42 @@ package api
 
 import (
 	"context"
+	"time"
 
+	"kube-kg/internal/config"
 	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+	"kube-kg/internal/processor"
 )
 
 // KubeviewClient is the interface for the Kubeview client.
 type KubeviewClient interface {
 	ListNamespaces(ctx context.Context) (*kubeview.NamespaceListResult, error)
+	CircuitState() kubeview.BreakerState
+	StreamStatus() kubeview.StreamStatus
+	ServerStatus(ctx context.Context) (*kubeview.ServerStatus, error)
 }
 
 // Neo4jClient is the interface for the Neo4j client.
 type Neo4jClient interface {
 	VerifyConnectivity(ctx context.Context) error
+	GraphCounts(ctx context.Context) (neo4j.GraphCounts, error)
 }
 
 // Processor is the interface for the processor.
 type Processor interface {
-	InitialSync(ctx context.Context) error
+	Refresh(ctx context.Context, scope processor.Scope, observer processor.SyncObserver) error
+	LastSyncReport() *processor.SyncReport
+	State() processor.State
+	InitialSyncCompleted() bool
+	QueuedEvents() int
+	BufferedEvents() int
+	CheckEventLoop(stallTimeout time.Duration) error
+}
+
+// ConfigReloader reloads the service's configuration.
+type ConfigReloader interface {
+	// Reload reads the configuration again and applies the settings that can change at runtime, returning
+	// every setting that changed. An invalid configuration is reported as a *config.ValidationError.
+	Reload(ctx context.Context) ([]config.Change, error)
 }
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"kube-kg/internal/processor"
+)
+
+// maxFinishedJobs is the number of finished refresh jobs kept for status polling.
+const maxFinishedJobs = 20
+
+// JobStatus is the state of a refresh job.
+type JobStatus string
+
+const (
+	// JobRunning means the refresh is still synchronizing namespaces.
+	JobRunning JobStatus = "running"
+	// JobSucceeded means every namespace was synchronized.
+	JobSucceeded JobStatus = "succeeded"
+	// JobFailed means the refresh finished, but some or all namespaces could not be synchronized.
+	JobFailed JobStatus = "failed"
+	// JobCancelled means the refresh was cancelled, through the API or by shutdown.
+	JobCancelled JobStatus = "cancelled"
+)
+
+// JobProgress counts the namespaces a refresh job has finished.
+type JobProgress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+}
+
+// Job is a snapshot of a refresh job.
+type Job struct {
+	ID         string                      `json:"id"`
+	Scope      *processor.Scope            `json:"scope,omitempty"`
+	Status     JobStatus                   `json:"status"`
+	StartedAt  time.Time                   `json:"started_at"`
+	FinishedAt *time.Time                  `json:"finished_at,omitempty"`
+	Progress   JobProgress                 `json:"progress"`
+	Namespaces []processor.NamespaceReport `json:"namespaces"`
+	Error      string                      `json:"error,omitempty"`
+}
+
+// refreshJob tracks a running or finished refresh. It observes the sync to record its progress.
+type refreshJob struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	job Job
+}
+
+func (j *refreshJob) SyncStarted(namespaces []string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Progress.Total = len(namespaces)
+}
+
+func (j *refreshJob) NamespaceFinished(report processor.NamespaceReport) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.job.Progress.Completed++
+	j.job.Namespaces = append(j.job.Namespaces, report)
+}
+
+func (j *refreshJob) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	job.Namespaces = slices.Clone(j.job.Namespaces)
+	if job.Namespaces == nil {
+		job.Namespaces = []processor.NamespaceReport{}
+	}
+	return job
+}
+
+func (j *refreshJob) finish(ctx context.Context, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	finishedAt := time.Now()
+	j.job.FinishedAt = &finishedAt
+	switch {
+	case ctx.Err() != nil:
+		j.job.Status = JobCancelled
+	case err != nil:
+		j.job.Status = JobFailed
+	default:
+		j.job.Status = JobSucceeded
+	}
+	if err != nil {
+		j.job.Error = err.Error()
+	}
+}
+
+// errJobFinished is returned when cancelling a job that is no longer running.
+var errJobFinished = errors.New("job has already finished")
+
+// errJobRunning is returned when starting a refresh while one with a different scope is running.
+var errJobRunning = errors.New("a refresh with a different scope is already running")
+
+// refreshJobs runs refreshes one at a time. Starting a refresh while one with the same scope is
+// running returns the running one, so concurrent requests are merged into a single sync.
+type refreshJobs struct {
+	processor Processor
+	// ctx is cancelled on shutdown, cancelling every running job.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	jobs     map[string]*refreshJob
+	finished []string
+	running  *refreshJob
+}
+
+func newRefreshJobs(p Processor) *refreshJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &refreshJobs{
+		processor: p,
+		ctx:       ctx,
+		cancel:    cancel,
+		jobs:      make(map[string]*refreshJob),
+	}
+}
+
+// start starts a refresh job, or returns the running one if it has the same scope. created reports
+// which. If a job with another scope is running, it is returned with errJobRunning.
+func (r *refreshJobs) start(scope processor.Scope) (job Job, created bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running != nil {
+		running := r.running.snapshot()
+		if !reflect.DeepEqual(running.Scope, scopeOf(scope)) {
+			return running, false, errJobRunning
+		}
+		return running, false, nil
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	j := &refreshJob{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		job:    Job{ID: rand.Text(), Scope: scopeOf(scope), Status: JobRunning, StartedAt: time.Now()},
+	}
+	r.jobs[j.job.ID] = j
+	r.running = j
+
+	go func() {
+		defer close(j.done)
+		defer cancel()
+		err := r.processor.Refresh(ctx, scope, j)
+		j.finish(ctx, err)
+		if err != nil {
+			slog.ErrorContext(ctx, "refresh job failed", "id", j.job.ID, "err", err)
+		} else {
+			slog.InfoContext(ctx, "refresh job completed", "id", j.job.ID)
+		}
+		r.retire(j)
+	}()
+	return j.snapshot(), true, nil
+}
+
+// scopeOf returns the scope a job reports: nil for the whole cluster.
+func scopeOf(scope processor.Scope) *processor.Scope {
+	if scope.IsZero() {
+		return nil
+	}
+	return &scope
+}
+
+// retire records a job as finished, forgetting the oldest finished jobs beyond maxFinishedJobs.
+func (r *refreshJobs) retire(j *refreshJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == j {
+		r.running = nil
+	}
+	r.finished = append(r.finished, j.job.ID)
+	for len(r.finished) > maxFinishedJobs {
+		delete(r.jobs, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+}
+
+func (r *refreshJobs) get(id string) (Job, bool) {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	return j.snapshot(), true
+}
+
+// cancelJob cancels a running job and waits for it to stop.
+func (r *refreshJobs) cancelJob(ctx context.Context, id string) (Job, bool, error) {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	r.mu.Unlock()
+	if !ok {
+		return Job{}, false, nil
+	}
+	select {
+	case <-j.done:
+		return j.snapshot(), true, errJobFinished
+	default:
+	}
+
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+	}
+	return j.snapshot(), true, nil
+}
+
+// shutdown cancels every running job and waits for them to stop.
+func (r *refreshJobs) shutdown(ctx context.Context) error {
+	r.cancel()
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+	if running == nil {
+		return nil
+	}
+	select {
+	case <-running.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
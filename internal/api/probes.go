@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeTTL is how long the result of a dependency check is reused when none is configured.
+	DefaultProbeTTL = 10 * time.Second
+	// DefaultStallTimeout is how long queued events may wait without progress before /livez fails,
+	// when none is configured.
+	DefaultStallTimeout = 2 * time.Minute
+	// probeTimeout bounds a single dependency check.
+	probeTimeout = 5 * time.Second
+)
+
+const (
+	statusOK          = "OK"
+	statusUnavailable = "Unavailable"
+)
+
+// errInitialSyncPending is reported by /readyz until a sync has completed.
+var errInitialSyncPending = errors.New("initial sync has not completed")
+
+// ProbeResponse is the body of the /livez and /readyz endpoints.
+type ProbeResponse struct {
+	Status       string                 `json:"status"`
+	Dependencies map[string]CheckResult `json:"dependencies"`
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// runCheck runs check and records its outcome and latency.
+func runCheck(ctx context.Context, check func(ctx context.Context) error) CheckResult {
+	start := time.Now()
+	err := check(ctx)
+	result := CheckResult{
+		Status:    statusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = statusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// cachedProbe runs a dependency check at most once per TTL, so that frequent probes do not
+// load the dependency and a single slow response does not fail every probe.
+type cachedProbe struct {
+	check func(ctx context.Context) error
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	result CheckResult
+}
+
+// get returns the cached result, running the check first if it is older than the TTL.
+// Concurrent callers wait for the same check.
+func (p *cachedProbe) get(ctx context.Context) CheckResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.result.CheckedAt.IsZero() && time.Since(p.result.CheckedAt) < p.ttl {
+		return p.result
+	}
+	// The check is shared by later callers, so it must not be cut short by this request.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeTimeout)
+	defer cancel()
+	p.result = runCheck(ctx, p.check)
+	return p.result
+}
+
+// handleLivez reports whether the process is able to make progress. It checks nothing outside
+// the process, so that an unreachable dependency does not get the pod restarted.
+func (s *Server) handleLivez() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventLoop := runCheck(r.Context(), func(context.Context) error {
+			return s.processor.CheckEventLoop(s.stallTimeout)
+		})
+		writeProbe(w, map[string]CheckResult{"event_loop": eventLoop})
+	}
+}
+
+// handleReadyz reports whether the service is ready to serve the graph: a full sync must have
+// settled every namespace and KubeView and Neo4j must be reachable. Dependency checks are cached.
+func (s *Server) handleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		initialSync := runCheck(r.Context(), func(context.Context) error {
+			if !s.processor.InitialSyncCompleted() {
+				return errInitialSyncPending
+			}
+			return nil
+		})
+		writeProbe(w, map[string]CheckResult{
+			"initial_sync": initialSync,
+			"kubeview":     s.kubeviewProbe.get(r.Context()),
+			"neo4j":        s.neo4jProbe.get(r.Context()),
+		})
+	}
+}
+
+// writeProbe responds with 200 OK if every check passed and 503 Service Unavailable otherwise.
+func writeProbe(w http.ResponseWriter, checks map[string]CheckResult) {
+	response := ProbeResponse{Status: statusOK, Dependencies: checks}
+	code := http.StatusOK
+	for _, check := range checks {
+		if check.Status != statusOK {
+			response.Status = statusUnavailable
+			code = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, response)
+}
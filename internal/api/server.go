@@ -3,8 +3,13 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"kube-kg/internal/processor"
 )
 
 // Server is the HTTP server.
@@ -13,16 +18,73 @@ type Server struct {
 	kubeviewClient KubeviewClient
 	neo4jClient    Neo4jClient
 	processor      Processor
+	refreshJobs    *refreshJobs
+	probeTTL       time.Duration
+	stallTimeout   time.Duration
+	kubeviewProbe  *cachedProbe
+	neo4jProbe     *cachedProbe
+	metrics        http.Handler
+	logLevel       *slog.LevelVar
+	configReloader ConfigReloader
+}
+
+// Option configures optional behaviour of a Server.
+type Option func(*Server)
+
+// WithProbeTTL sets how long /readyz reuses the result of a dependency check.
+func WithProbeTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.probeTTL = ttl
+	}
+}
+
+// WithStallTimeout sets how long queued events may wait without progress before /livez fails.
+func WithStallTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.stallTimeout = timeout
+	}
+}
+
+// WithMetricsHandler serves h, typically a Prometheus exporter, on /metrics.
+func WithMetricsHandler(h http.Handler) Option {
+	return func(s *Server) {
+		s.metrics = h
+	}
+}
+
+// WithLogLevel lets /admin/log-level read and change the level of the service's logger.
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.logLevel = level
+	}
+}
+
+// WithConfigReloader lets POST /config/reload reload the configuration with r.
+func WithConfigReloader(r ConfigReloader) Option {
+	return func(s *Server) {
+		s.configReloader = r
+	}
 }
 
 // NewServer creates a new HTTP server.
-func NewServer(kc KubeviewClient, nc Neo4jClient, p Processor) *Server {
+func NewServer(kc KubeviewClient, nc Neo4jClient, p Processor, opts ...Option) *Server {
 	s := &Server{
 		router:         http.NewServeMux(),
 		kubeviewClient: kc,
 		neo4jClient:    nc,
 		processor:      p,
+		refreshJobs:    newRefreshJobs(p),
+		probeTTL:       DefaultProbeTTL,
+		stallTimeout:   DefaultStallTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.kubeviewProbe = &cachedProbe{ttl: s.probeTTL, check: func(ctx context.Context) error {
+		_, err := kc.ServerStatus(ctx)
+		return err
+	}}
+	s.neo4jProbe = &cachedProbe{ttl: s.probeTTL, check: nc.VerifyConnectivity}
 	s.routes()
 	return s
 }
@@ -31,9 +93,30 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
+// Shutdown cancels the running refresh job and waits for it to stop.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.refreshJobs.shutdown(ctx)
+}
+
 func (s *Server) routes() {
 	s.router.HandleFunc("/health", s.handleHealth())
-	s.router.HandleFunc("/refresh", s.handleRefresh())
+	s.router.HandleFunc("GET /livez", s.handleLivez())
+	s.router.HandleFunc("GET /readyz", s.handleReadyz())
+	s.router.HandleFunc("POST /refresh", s.handleRefresh())
+	s.router.HandleFunc("GET /refresh/{id}", s.handleRefreshStatus())
+	s.router.HandleFunc("DELETE /refresh/{id}", s.handleRefreshCancel())
+	s.router.HandleFunc("/sync/report", s.handleSyncReport())
+	s.router.HandleFunc("GET /status", s.handleStatus())
+	if s.metrics != nil {
+		s.router.Handle("GET /metrics", s.metrics)
+	}
+	if s.logLevel != nil {
+		s.router.HandleFunc("GET /admin/log-level", s.handleGetLogLevel())
+		s.router.HandleFunc("PUT /admin/log-level", s.handleSetLogLevel())
+	}
+	if s.configReloader != nil {
+		s.router.HandleFunc("POST /config/reload", s.handleConfigReload())
+	}
 }
 
 func (s *Server) handleHealth() http.HandlerFunc {
@@ -51,6 +134,8 @@ func (s *Server) handleHealth() http.HandlerFunc {
 			response["kubeview"] = "ok"
 		}
 
+		response["kubeview_circuit"] = string(s.kubeviewClient.CircuitState())
+
 		if neo4jHealth != nil {
 			status = http.StatusServiceUnavailable
 			response["neo4j"] = "unavailable"
@@ -62,21 +147,86 @@ func (s *Server) handleHealth() http.HandlerFunc {
 		w.WriteHeader(status)
 		err := json.NewEncoder(w).Encode(response)
 		if err != nil {
-			slog.Error("failed to encode response", "err", err)
+			slog.ErrorContext(r.Context(), "failed to encode response", "err", err)
 			return
 		}
 	}
 }
 
+// handleRefresh starts a refresh job for the scope in the request body, or for the whole cluster if
+// there is none. While a job for the same scope is running, its ID is returned instead of starting another.
 func (s *Server) handleRefresh() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		go func() {
-			if err := s.processor.InitialSync(context.Background()); err != nil {
-				// Log the error, but don't block the response
-				slog.Error("error during refresh sync:", "err", err)
+		var scope processor.Scope
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&scope); err != nil && !errors.Is(err, io.EOF) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid refresh scope: " + err.Error()})
 				return
 			}
-		}()
-		w.WriteHeader(http.StatusAccepted)
+		}
+		if err := scope.Validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		job, created, err := s.refreshJobs.start(scope)
+		w.Header().Set("Location", "/refresh/"+job.ID)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, job)
+			return
+		}
+		if !created {
+			slog.InfoContext(r.Context(), "refresh already running, merging request", "id", job.ID)
+		}
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func (s *Server) handleRefreshStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := s.refreshJobs.get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "refresh job not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) handleRefreshCancel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok, err := s.refreshJobs.cancelJob(r.Context(), r.PathValue("id"))
+		switch {
+		case !ok:
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "refresh job not found"})
+		case err != nil:
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, job)
+		}
+	}
+}
+
+func (s *Server) handleSyncReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := s.processor.LastSyncReport()
+
+		w.Header().Set("Content-Type", "application/json")
+		var body interface{} = report
+		if report == nil {
+			w.WriteHeader(http.StatusNotFound)
+			body = map[string]string{"error": "no sync has completed yet"}
+		}
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			slog.ErrorContext(r.Context(), "failed to encode response", "err", err)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to encode response", "err", err)
 	}
 }
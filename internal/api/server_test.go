@@ -2,14 +2,22 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"kube-kg/internal/config"
 	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+	"kube-kg/internal/processor"
 )
 
 // MockKubeviewClient is a mock implementation of the KubeviewClient interface.
@@ -25,6 +33,22 @@ func (m *MockKubeviewClient) ListNamespaces(ctx context.Context) (*kubeview.Name
 	return args.Get(0).(*kubeview.NamespaceListResult), args.Error(1)
 }
 
+func (m *MockKubeviewClient) CircuitState() kubeview.BreakerState {
+	args := m.Called()
+	return args.Get(0).(kubeview.BreakerState)
+}
+
+func (m *MockKubeviewClient) StreamStatus() kubeview.StreamStatus {
+	args := m.Called()
+	return args.Get(0).(kubeview.StreamStatus)
+}
+
+func (m *MockKubeviewClient) ServerStatus(ctx context.Context) (*kubeview.ServerStatus, error) {
+	args := m.Called(ctx)
+	status, _ := args.Get(0).(*kubeview.ServerStatus)
+	return status, args.Error(1)
+}
+
 // MockNeo4jClient is a mock implementation of the Neo4jClient interface.
 type MockNeo4jClient struct {
 	mock.Mock
@@ -35,15 +59,54 @@ func (m *MockNeo4jClient) VerifyConnectivity(ctx context.Context) error {
 	return args.Error(0)
 }
 
+func (m *MockNeo4jClient) GraphCounts(ctx context.Context) (neo4j.GraphCounts, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(neo4j.GraphCounts), args.Error(1)
+}
+
 // MockProcessor is a mock implementation of the Processor interface.
 type MockProcessor struct {
 	mock.Mock
-	initialSyncCalled chan bool
 }
 
-func (m *MockProcessor) InitialSync(ctx context.Context) error {
-	args := m.Called(ctx)
-	m.initialSyncCalled <- true
+// Refresh returns the error set up for the call, or, if a function was set up instead, calls it.
+// The observer is not passed to the mock, as formatting it while it is in use would race.
+func (m *MockProcessor) Refresh(ctx context.Context, scope processor.Scope, observer processor.SyncObserver) error {
+	args := m.Called(ctx, scope)
+	if refresh, ok := args.Get(0).(func(context.Context, processor.SyncObserver) error); ok {
+		return refresh(ctx, observer)
+	}
+	return args.Error(0)
+}
+
+func (m *MockProcessor) LastSyncReport() *processor.SyncReport {
+	args := m.Called()
+	report, _ := args.Get(0).(*processor.SyncReport)
+	return report
+}
+
+func (m *MockProcessor) State() processor.State {
+	args := m.Called()
+	return args.Get(0).(processor.State)
+}
+
+func (m *MockProcessor) InitialSyncCompleted() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *MockProcessor) QueuedEvents() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
+func (m *MockProcessor) BufferedEvents() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
+func (m *MockProcessor) CheckEventLoop(stallTimeout time.Duration) error {
+	args := m.Called(stallTimeout)
 	return args.Error(0)
 }
 
@@ -54,6 +117,7 @@ func TestHealthHandler(t *testing.T) {
 		p := new(MockProcessor)
 
 		kc.On("ListNamespaces", mock.Anything).Return(&kubeview.NamespaceListResult{}, nil)
+		kc.On("CircuitState").Return(kubeview.BreakerClosed)
 		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
 
 		server := NewServer(kc, nc, p)
@@ -64,7 +128,7 @@ func TestHealthHandler(t *testing.T) {
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusOK, rr.Code)
-		assert.JSONEq(t, `{"kubeview":"ok","neo4j":"ok"}`, rr.Body.String())
+		assert.JSONEq(t, `{"kubeview":"ok","kubeview_circuit":"closed","neo4j":"ok"}`, rr.Body.String())
 	})
 
 	t.Run("should return 503 Service Unavailable when kubeview is down", func(t *testing.T) {
@@ -73,6 +137,7 @@ func TestHealthHandler(t *testing.T) {
 		p := new(MockProcessor)
 
 		kc.On("ListNamespaces", mock.Anything).Return(nil, assert.AnError)
+		kc.On("CircuitState").Return(kubeview.BreakerOpen)
 		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
 
 		server := NewServer(kc, nc, p)
@@ -83,7 +148,7 @@ func TestHealthHandler(t *testing.T) {
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
-		assert.JSONEq(t, `{"kubeview":"unavailable","neo4j":"ok"}`, rr.Body.String())
+		assert.JSONEq(t, `{"kubeview":"unavailable","kubeview_circuit":"open","neo4j":"ok"}`, rr.Body.String())
 	})
 
 	t.Run("should return 503 Service Unavailable when neo4j is down", func(t *testing.T) {
@@ -92,6 +157,7 @@ func TestHealthHandler(t *testing.T) {
 		p := new(MockProcessor)
 
 		kc.On("ListNamespaces", mock.Anything).Return(&kubeview.NamespaceListResult{}, nil)
+		kc.On("CircuitState").Return(kubeview.BreakerClosed)
 		nc.On("VerifyConnectivity", mock.Anything).Return(assert.AnError)
 
 		server := NewServer(kc, nc, p)
@@ -102,34 +168,575 @@ func TestHealthHandler(t *testing.T) {
 		server.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
-		assert.JSONEq(t, `{"kubeview":"ok","neo4j":"unavailable"}`, rr.Body.String())
+		assert.JSONEq(t, `{"kubeview":"ok","kubeview_circuit":"closed","neo4j":"unavailable"}`, rr.Body.String())
 	})
 }
 
+// refresh sends a request for a refresh job and decodes the job in the response.
+func refresh(t *testing.T, server *Server, method, path string, wantStatus int) Job {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+	require.Equal(t, wantStatus, rr.Code, rr.Body.String())
+
+	var job Job
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&job))
+	return job
+}
+
 func TestRefreshHandler(t *testing.T) {
-	t.Run("should return 202 Accepted and trigger initial sync", func(t *testing.T) {
+	t.Run("should run the refresh as a job and report its progress", func(t *testing.T) {
+		p := new(MockProcessor)
+		release := make(chan struct{})
+		p.On("Refresh", mock.Anything, mock.Anything).Return(func(_ context.Context, observer processor.SyncObserver) error {
+			observer.SyncStarted([]string{"default", "team-a"})
+			observer.NamespaceFinished(processor.NamespaceReport{Namespace: "default", Status: processor.NamespaceSynced})
+			<-release
+			observer.NamespaceFinished(processor.NamespaceReport{Namespace: "team-a", Status: processor.NamespaceSynced})
+			return nil
+		})
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+
+		started := refresh(t, server, http.MethodPost, "/refresh", http.StatusAccepted)
+		assert.NotEmpty(t, started.ID)
+		assert.Equal(t, JobRunning, started.Status)
+
+		// A second request while the first is running joins it.
+		merged := refresh(t, server, http.MethodPost, "/refresh", http.StatusAccepted)
+		assert.Equal(t, started.ID, merged.ID)
+
+		require.Eventually(t, func() bool {
+			job := refresh(t, server, http.MethodGet, "/refresh/"+started.ID, http.StatusOK)
+			return job.Progress == JobProgress{Completed: 1, Total: 2}
+		}, time.Second, 10*time.Millisecond)
+
+		close(release)
+		var job Job
+		require.Eventually(t, func() bool {
+			job = refresh(t, server, http.MethodGet, "/refresh/"+started.ID, http.StatusOK)
+			return job.Status == JobSucceeded
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, JobProgress{Completed: 2, Total: 2}, job.Progress)
+		assert.Len(t, job.Namespaces, 2)
+		assert.NotNil(t, job.FinishedAt)
+		p.AssertNumberOfCalls(t, "Refresh", 1)
+
+		// Once finished, a new request starts a new job.
+		next := refresh(t, server, http.MethodPost, "/refresh", http.StatusAccepted)
+		assert.NotEqual(t, started.ID, next.ID)
+		require.NoError(t, server.Shutdown(context.Background()))
+	})
+
+	t.Run("should cancel a running job", func(t *testing.T) {
+		p := new(MockProcessor)
+		p.On("Refresh", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ processor.SyncObserver) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+		started := refresh(t, server, http.MethodPost, "/refresh", http.StatusAccepted)
+
+		cancelled := refresh(t, server, http.MethodDelete, "/refresh/"+started.ID, http.StatusOK)
+		assert.Equal(t, JobCancelled, cancelled.Status)
+
+		req := httptest.NewRequest(http.MethodDelete, "/refresh/"+started.ID, nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("should cancel running jobs on shutdown", func(t *testing.T) {
+		p := new(MockProcessor)
+		p.On("Refresh", mock.Anything, mock.Anything).Return(func(ctx context.Context, _ processor.SyncObserver) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+		started := refresh(t, server, http.MethodPost, "/refresh", http.StatusAccepted)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, server.Shutdown(ctx))
+
+		job := refresh(t, server, http.MethodGet, "/refresh/"+started.ID, http.StatusOK)
+		assert.Equal(t, JobCancelled, job.Status)
+	})
+
+	t.Run("should refresh the scope in the request body", func(t *testing.T) {
+		p := new(MockProcessor)
+		release := make(chan struct{})
+		scope := processor.Scope{Namespaces: []string{"team-a"}, Kinds: []string{"Pod"}}
+		p.On("Refresh", mock.Anything, scope).Return(func(context.Context, processor.SyncObserver) error {
+			<-release
+			return nil
+		})
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+		body := `{"namespaces":["team-a"],"kinds":["Pod"]}`
+
+		req := httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		var started Job
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&started))
+		assert.Equal(t, &scope, started.Scope)
+
+		// A refresh of another scope is refused while this one runs.
+		conflict := refresh(t, server, http.MethodPost, "/refresh", http.StatusConflict)
+		assert.Equal(t, started.ID, conflict.ID)
+
+		close(release)
+		require.NoError(t, server.Shutdown(context.Background()))
+	})
+
+	t.Run("should return 400 Bad Request for an invalid scope", func(t *testing.T) {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor))
+
+		for _, body := range []string{`{"namespaces":`, `{"resource":{"namespace":"default"}}`} {
+			req := httptest.NewRequest(http.MethodPost, "/refresh", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			server.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		}
+	})
+
+	t.Run("should return 404 Not Found for an unknown job", func(t *testing.T) {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor))
+
+		req := httptest.NewRequest(http.MethodGet, "/refresh/unknown", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestSyncReportHandler(t *testing.T) {
+	t.Run("should return 404 Not Found before the first sync", func(t *testing.T) {
+		p := new(MockProcessor)
+		p.On("LastSyncReport").Return(nil)
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+
+		req := httptest.NewRequest(http.MethodGet, "/sync/report", nil)
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.JSONEq(t, `{"error":"no sync has completed yet"}`, rr.Body.String())
+	})
+
+	t.Run("should return the last sync report", func(t *testing.T) {
+		started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		p := new(MockProcessor)
+		p.On("LastSyncReport").Return(&processor.SyncReport{
+			Generation: 42,
+			StartedAt:  started,
+			FinishedAt: started.Add(2 * time.Second),
+			Namespaces: []processor.NamespaceReport{
+				{Namespace: "default", Status: processor.NamespaceSynced, Attempts: 1, Resources: 3,
+					Relationships: 2, PrunedNodes: 1, DurationSeconds: 1.5},
+				{Namespace: "restricted", Status: processor.NamespaceFailed, Attempts: 1,
+					Error: "unexpected status code: 403"},
+			},
+		})
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+
+		req := httptest.NewRequest(http.MethodGet, "/sync/report", nil)
+		rr := httptest.NewRecorder()
+
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{
+			"generation": 42,
+			"started_at": "2024-01-02T03:04:05Z",
+			"finished_at": "2024-01-02T03:04:07Z",
+			"namespaces": [
+				{"namespace": "default", "status": "synced", "attempts": 1, "resources": 3, "relationships": 2,
+					"pruned_nodes": 1, "pruned_relationships": 0, "duration_seconds": 1.5},
+				{"namespace": "restricted", "status": "failed", "attempts": 1, "resources": 0, "relationships": 0,
+					"pruned_nodes": 0, "pruned_relationships": 0, "duration_seconds": 0,
+					"error": "unexpected status code: 403"}
+			]
+		}`, rr.Body.String())
+	})
+}
+
+func TestStatusHandler(t *testing.T) {
+	newProcessor := func() *MockProcessor {
+		p := new(MockProcessor)
+		p.On("State").Return(processor.StateLive)
+		p.On("QueuedEvents").Return(3)
+		p.On("BufferedEvents").Return(0)
+		p.On("LastSyncReport").Return(&processor.SyncReport{Generation: 42})
+		return p
+	}
+	lastEventAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should report the processor, stream, graph and kubeview", func(t *testing.T) {
 		kc := new(MockKubeviewClient)
+		kc.On("StreamStatus").Return(kubeview.StreamStatus{Connected: true, Reconnects: 2, LastEventAt: &lastEventAt})
+		kc.On("CircuitState").Return(kubeview.BreakerClosed)
+		kc.On("ServerStatus", mock.Anything).Return(&kubeview.ServerStatus{Version: "2.1.0", BuildInfo: "abc123"}, nil)
 		nc := new(MockNeo4jClient)
-		p := &MockProcessor{
-			initialSyncCalled: make(chan bool, 1),
-		}
+		nc.On("GraphCounts", mock.Anything).Return(neo4j.GraphCounts{
+			Nodes:         map[string]int64{"Pod": 5},
+			Relationships: map[string]int64{"SELECTS": 4},
+		}, nil)
 
-		p.On("InitialSync", mock.Anything).Return(nil)
+		server := NewServer(kc, nc, newProcessor())
 
-		server := NewServer(kc, nc, p)
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
 
-		req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{
+			"processor": {"state": "live", "queued_events": 3, "buffered_events": 0, "last_sync": {
+				"generation": 42, "started_at": "0001-01-01T00:00:00Z", "finished_at": "0001-01-01T00:00:00Z",
+				"namespaces": null
+			}},
+			"stream": {"connected": true, "reconnects": 2, "last_event_at": "2026-01-02T03:04:05Z", "circuit": "closed"},
+			"graph": {"nodes": {"Pod": 5}, "relationships": {"SELECTS": 4}},
+			"kubeview": {"version": "2.1.0", "build_info": "abc123"}
+		}`, rr.Body.String())
+	})
+
+	t.Run("should bound the KubeView request", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("StreamStatus").Return(kubeview.StreamStatus{})
+		kc.On("CircuitState").Return(kubeview.BreakerClosed)
+		hasDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+			_, ok := ctx.Deadline()
+			return ok
+		})
+		kc.On("ServerStatus", hasDeadline).Return(&kubeview.ServerStatus{}, nil)
+		nc := new(MockNeo4jClient)
+		nc.On("GraphCounts", mock.Anything).Return(neo4j.GraphCounts{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
 		rr := httptest.NewRecorder()
+		NewServer(kc, nc, newProcessor()).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		kc.AssertExpectations(t)
+	})
+
+	t.Run("should report unreachable dependencies in their section", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("StreamStatus").Return(kubeview.StreamStatus{})
+		kc.On("CircuitState").Return(kubeview.BreakerOpen)
+		kc.On("ServerStatus", mock.Anything).Return(nil, errors.New("circuit breaker is open"))
+		nc := new(MockNeo4jClient)
+		nc.On("GraphCounts", mock.Anything).Return(neo4j.GraphCounts{}, errors.New("connection refused"))
+
+		server := NewServer(kc, nc, newProcessor())
+
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var status Status
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&status))
+		assert.Equal(t, "connection refused", status.Graph.Error)
+		assert.Equal(t, "circuit breaker is open", status.Kubeview.Error)
+		assert.False(t, status.Stream.Connected)
+	})
+}
+
+func TestLivezHandler(t *testing.T) {
+	t.Run("should return 200 OK without checking dependencies", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		nc := new(MockNeo4jClient)
+		p := new(MockProcessor)
+		p.On("CheckEventLoop", time.Minute).Return(nil)
+
+		server := NewServer(kc, nc, p, WithStallTimeout(time.Minute))
 
+		req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+		rr := httptest.NewRecorder()
 		server.ServeHTTP(rr, req)
 
-		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response ProbeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "OK", response.Status)
+		assert.Equal(t, "OK", response.Dependencies["event_loop"].Status)
+		kc.AssertNotCalled(t, "ServerStatus", mock.Anything)
+		nc.AssertNotCalled(t, "VerifyConnectivity", mock.Anything)
+	})
 
-		select {
-		case <-p.initialSyncCalled:
-			// success
-		case <-time.After(1 * time.Second):
-			t.Fatal("InitialSync was not called within 1 second")
+	t.Run("should return 503 when the event loop has stalled", func(t *testing.T) {
+		p := new(MockProcessor)
+		p.On("CheckEventLoop", DefaultStallTimeout).Return(errors.New("event workers have made no progress"))
+
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), p)
+
+		req := httptest.NewRequest(http.MethodGet, "/livez", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		var response ProbeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "Unavailable", response.Status)
+		assert.Equal(t, "event workers have made no progress", response.Dependencies["event_loop"].Error)
+	})
+}
+
+func TestReadyzHandler(t *testing.T) {
+	synced := func() *MockProcessor {
+		p := new(MockProcessor)
+		p.On("InitialSyncCompleted").Return(true)
+		return p
+	}
+	get := func(server *Server) (int, ProbeResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		var response ProbeResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		return rr.Code, response
+	}
+
+	t.Run("should return 200 OK once synced with reachable dependencies", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("ServerStatus", mock.Anything).Return(&kubeview.ServerStatus{}, nil)
+		nc := new(MockNeo4jClient)
+		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
+
+		code, response := get(NewServer(kc, nc, synced()))
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "OK", response.Status)
+		for _, name := range []string{"initial_sync", "kubeview", "neo4j"} {
+			assert.Equal(t, "OK", response.Dependencies[name].Status, name)
+			assert.False(t, response.Dependencies[name].CheckedAt.IsZero(), name)
 		}
 	})
+
+	t.Run("should return 503 until the initial sync has completed", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("ServerStatus", mock.Anything).Return(&kubeview.ServerStatus{}, nil)
+		nc := new(MockNeo4jClient)
+		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
+		p := new(MockProcessor)
+		p.On("InitialSyncCompleted").Return(false)
+
+		code, response := get(NewServer(kc, nc, p))
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "Unavailable", response.Status)
+		assert.Equal(t, "initial sync has not completed", response.Dependencies["initial_sync"].Error)
+		assert.Equal(t, "OK", response.Dependencies["neo4j"].Status)
+	})
+
+	t.Run("should return 503 when a dependency is unreachable", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("ServerStatus", mock.Anything).Return(nil, errors.New("connection refused"))
+		nc := new(MockNeo4jClient)
+		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
+
+		code, response := get(NewServer(kc, nc, synced()))
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "connection refused", response.Dependencies["kubeview"].Error)
+	})
+
+	t.Run("should reuse dependency checks within the TTL", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("ServerStatus", mock.Anything).Return(&kubeview.ServerStatus{}, nil)
+		nc := new(MockNeo4jClient)
+		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
+		server := NewServer(kc, nc, synced(), WithProbeTTL(time.Hour))
+
+		_, first := get(server)
+		_, second := get(server)
+
+		kc.AssertNumberOfCalls(t, "ServerStatus", 1)
+		nc.AssertNumberOfCalls(t, "VerifyConnectivity", 1)
+		assert.True(t, first.Dependencies["neo4j"].CheckedAt.Equal(second.Dependencies["neo4j"].CheckedAt))
+	})
+
+	t.Run("should check dependencies again once the TTL has passed", func(t *testing.T) {
+		kc := new(MockKubeviewClient)
+		kc.On("ServerStatus", mock.Anything).Return(&kubeview.ServerStatus{}, nil)
+		nc := new(MockNeo4jClient)
+		nc.On("VerifyConnectivity", mock.Anything).Return(nil)
+		server := NewServer(kc, nc, synced(), WithProbeTTL(time.Nanosecond))
+
+		get(server)
+		time.Sleep(time.Millisecond)
+		get(server)
+
+		kc.AssertNumberOfCalls(t, "ServerStatus", 2)
+		nc.AssertNumberOfCalls(t, "VerifyConnectivity", 2)
+	})
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Run("should serve the metrics handler", func(t *testing.T) {
+		metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("processor_events_received_total 1\n"))
+		})
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor), WithMetricsHandler(metrics))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "processor_events_received_total 1\n", rr.Body.String())
+	})
+
+	t.Run("should not serve metrics without a handler", func(t *testing.T) {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	newServer := func(level *slog.LevelVar) *Server {
+		return NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor), WithLogLevel(level))
+	}
+
+	t.Run("should return the current level", func(t *testing.T) {
+		var level slog.LevelVar
+		level.Set(slog.LevelWarn)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+		rr := httptest.NewRecorder()
+		newServer(&level).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"level":"warn"}`, rr.Body.String())
+	})
+
+	t.Run("should change the level", func(t *testing.T) {
+		var level slog.LevelVar
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"debug"}`))
+		rr := httptest.NewRecorder()
+		newServer(&level).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"level":"debug"}`, rr.Body.String())
+		assert.Equal(t, slog.LevelDebug, level.Level())
+	})
+
+	t.Run("should reject an unknown level", func(t *testing.T) {
+		var level slog.LevelVar
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(`{"level":"verbose"}`))
+		rr := httptest.NewRecorder()
+		newServer(&level).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, slog.LevelInfo, level.Level())
+	})
+
+	t.Run("should not serve the endpoint without a level", func(t *testing.T) {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor))
+
+		req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+// MockConfigReloader is a mock implementation of the ConfigReloader interface.
+type MockConfigReloader struct {
+	mock.Mock
+}
+
+func (m *MockConfigReloader) Reload(ctx context.Context) ([]config.Change, error) {
+	args := m.Called(ctx)
+	changes, _ := args.Get(0).([]config.Change)
+	return changes, args.Error(1)
+}
+
+func TestConfigReloadHandler(t *testing.T) {
+	reload := func(reloader *MockConfigReloader) *httptest.ResponseRecorder {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor),
+			WithConfigReloader(reloader))
+		req := httptest.NewRequest(http.MethodPost, "/config/reload", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should return the changed settings", func(t *testing.T) {
+		reloader := new(MockConfigReloader)
+		reloader.On("Reload", mock.Anything).Return([]config.Change{
+			{Setting: "neo4j_password", Old: "REDACTED", New: "REDACTED", Reloadable: true},
+			{Setting: "event_workers", Old: "4", New: "8"},
+		}, nil)
+
+		rr := reload(reloader)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"changes": [
+			{"setting": "neo4j_password", "old": "REDACTED", "new": "REDACTED", "reloadable": true},
+			{"setting": "event_workers", "old": "4", "new": "8", "reloadable": false}
+		]}`, rr.Body.String())
+	})
+
+	t.Run("should return an empty list when nothing changed", func(t *testing.T) {
+		reloader := new(MockConfigReloader)
+		reloader.On("Reload", mock.Anything).Return(nil, nil)
+
+		rr := reload(reloader)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"changes": []}`, rr.Body.String())
+	})
+
+	t.Run("should list the problems of an invalid configuration", func(t *testing.T) {
+		reloader := new(MockConfigReloader)
+		problems := []string{"kubeview_url is required", "event_workers must be positive, got 0"}
+		reloader.On("Reload", mock.Anything).Return(nil, &config.ValidationError{Problems: problems})
+
+		rr := reload(reloader)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		var body ConfigReloadError
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+		assert.Equal(t, problems, body.Problems)
+	})
+
+	t.Run("should fail when the configuration cannot be applied", func(t *testing.T) {
+		reloader := new(MockConfigReloader)
+		reloader.On("Reload", mock.Anything).Return(nil, errors.New("failed to verify Neo4j connectivity"))
+
+		rr := reload(reloader)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+
+	t.Run("should not serve the endpoint without a reloader", func(t *testing.T) {
+		server := NewServer(new(MockKubeviewClient), new(MockNeo4jClient), new(MockProcessor))
+
+		req := httptest.NewRequest(http.MethodPost, "/config/reload", nil)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
 }
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+	"kube-kg/internal/processor"
+)
+
+// Status is the response of the /status endpoint.
+type Status struct {
+	Processor ProcessorStatus `json:"processor"`
+	Stream    StreamStatus    `json:"stream"`
+	Graph     GraphStatus     `json:"graph"`
+	Kubeview  KubeviewStatus  `json:"kubeview"`
+}
+
+// ProcessorStatus describes the lifecycle of the processor and the events it has yet to apply.
+type ProcessorStatus struct {
+	State          processor.State       `json:"state"`
+	QueuedEvents   int                   `json:"queued_events"`
+	BufferedEvents int                   `json:"buffered_events"`
+	LastSync       *processor.SyncReport `json:"last_sync"`
+}
+
+// StreamStatus describes the SSE connection to KubeView.
+type StreamStatus struct {
+	kubeview.StreamStatus
+	Circuit kubeview.BreakerState `json:"circuit"`
+}
+
+// GraphStatus holds the node and relationship counts of the graph, or why they could not be read.
+type GraphStatus struct {
+	*neo4j.GraphCounts
+	Error string `json:"error,omitempty"`
+}
+
+// KubeviewStatus holds the version of the KubeView server, or why it could not be read.
+type KubeviewStatus struct {
+	Version     string `json:"version,omitempty"`
+	BuildInfo   string `json:"build_info,omitempty"`
+	ClusterHost string `json:"cluster_host,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// handleStatus reports the state of the service. It responds with 200 OK even if Neo4j or KubeView
+// cannot be reached, reporting the error in their section instead. KubeView is asked once, for at
+// most probeTimeout.
+func (s *Server) handleStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := Status{
+			Processor: ProcessorStatus{
+				State:          s.processor.State(),
+				QueuedEvents:   s.processor.QueuedEvents(),
+				BufferedEvents: s.processor.BufferedEvents(),
+				LastSync:       s.processor.LastSyncReport(),
+			},
+			Stream: StreamStatus{
+				StreamStatus: s.kubeviewClient.StreamStatus(),
+				Circuit:      s.kubeviewClient.CircuitState(),
+			},
+		}
+
+		if counts, err := s.neo4jClient.GraphCounts(r.Context()); err != nil {
+			status.Graph.Error = err.Error()
+		} else {
+			status.Graph.GraphCounts = &counts
+		}
+
+		// Dashboards poll this endpoint, so an unreachable KubeView must not hold up the response.
+		ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+		defer cancel()
+		if server, err := s.kubeviewClient.ServerStatus(ctx); err != nil {
+			status.Kubeview.Error = err.Error()
+		} else {
+			status.Kubeview = KubeviewStatus{
+				Version:     server.Version,
+				BuildInfo:   server.BuildInfo,
+				ClusterHost: server.ClusterHost,
+			}
+		}
+
+		writeJSON(w, http.StatusOK, status)
+	}
+}
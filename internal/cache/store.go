@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"sync"
+
+	"kube-kg/internal/kubeview"
+)
+
+// nameKey identifies a resource by namespace, kind and name.
+type nameKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+// kindKey identifies the resources of one kind within a namespace.
+type kindKey struct {
+	namespace string
+	kind      string
+}
+
+// labelKey identifies the resources of a namespace carrying a label with a given value.
+type labelKey struct {
+	namespace string
+	label     string
+	value     string
+}
+
+// uidSet is a set of resource uids.
+type uidSet map[string]struct{}
+
+// Store is an indexed, in-memory copy of the Kubernetes resources known to kube-kg.
+// It is seeded by the initial sync and kept current by real-time events, and lets
+// relationships be resolved without querying KubeView or Neo4j. It is safe for
+// concurrent use.
+type Store struct {
+	mu      sync.RWMutex
+	byUID   map[string]kubeview.KubernetesResource
+	byName  map[nameKey]string
+	byKind  map[kindKey]uidSet
+	byLabel map[labelKey]uidSet
+	byOwner map[string]uidSet
+	// tombstones holds the resources deleted since TrackDeletes, by uid. It is nil while deletes are not tracked.
+	tombstones map[string]kubeview.KubernetesResource
+}
+
+// NewStore creates an empty Store.
+func NewStore() *Store {
+	return &Store{
+		byUID:   make(map[string]kubeview.KubernetesResource),
+		byName:  make(map[nameKey]string),
+		byKind:  make(map[kindKey]uidSet),
+		byLabel: make(map[labelKey]uidSet),
+		byOwner: make(map[string]uidSet),
+	}
+}
+
+// Upsert adds a resource to the store or replaces the stored version of it. It reports
+// false, leaving the store unchanged, when the stored version is newer than resource or
+// a tombstone shows that resource has been deleted since.
+func (s *Store) Upsert(resource kubeview.KubernetesResource) bool {
+	if resource.Metadata.UID == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.byUID[resource.Metadata.UID]; ok && newer(stored, resource) {
+		return false
+	}
+	if s.deleted(resource) {
+		return false
+	}
+	s.remove(resource.Metadata.UID)
+	s.add(resource)
+	return true
+}
+
+// Delete removes a resource and returns the stored version of it, if there was one. While deletes are
+// tracked, it also keeps a tombstone with the version deleted.
+func (s *Store) Delete(resource kubeview.KubernetesResource) (kubeview.KubernetesResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tombstones != nil {
+		s.tombstones[resource.Metadata.UID] = resource
+	}
+	return s.remove(resource.Metadata.UID)
+}
+
+// TrackDeletes starts keeping a tombstone for every resource deleted, until ForgetDeletes is called.
+// A sync tracks deletes while it runs, so that resources deleted after their namespace was fetched
+// are not brought back by Upsert, ReplaceNamespace or ReplaceMatching.
+func (s *Store) TrackDeletes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tombstones = make(map[string]kubeview.KubernetesResource)
+}
+
+// ForgetDeletes drops the tombstones and stops keeping them.
+func (s *Store) ForgetDeletes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tombstones = nil
+}
+
+// deleted reports whether a tombstone shows that resource was deleted at or after its version.
+// The caller must hold the lock.
+func (s *Store) deleted(resource kubeview.KubernetesResource) bool {
+	tombstone, ok := s.tombstones[resource.Metadata.UID]
+	return ok && !newer(resource, tombstone)
+}
+
+// ReplaceNamespace replaces every stored resource of a namespace with resources. Stored
+// versions newer than those in resources are kept; their uids are returned. Resources that
+// a tombstone shows to have been deleted since are left out.
+func (s *Store) ReplaceNamespace(namespace string, resources []kubeview.KubernetesResource) []string {
+	return s.ReplaceMatching(namespace, nil, resources)
+}
+
+// ReplaceMatching is like ReplaceNamespace, but only replaces the stored resources of the
+// namespace for which match returns true. A nil match matches every resource.
+func (s *Store) ReplaceMatching(namespace string, match func(kubeview.KubernetesResource) bool,
+	resources []kubeview.KubernetesResource) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]kubeview.KubernetesResource)
+	for uid, resource := range s.byUID {
+		if resource.Metadata.Namespace == namespace && (match == nil || match(resource)) {
+			previous[uid] = resource
+			s.remove(uid)
+		}
+	}
+	var kept []string
+	for _, resource := range resources {
+		if resource.Metadata.UID == "" || s.deleted(resource) {
+			continue
+		}
+		if stored, ok := previous[resource.Metadata.UID]; ok && newer(stored, resource) {
+			resource = stored
+			kept = append(kept, stored.Metadata.UID)
+		}
+		s.remove(resource.Metadata.UID)
+		s.add(resource)
+	}
+	return kept
+}
+
+// newer reports whether a has a higher resource version than b. Versions that are not
+// numeric cannot be ordered and are never considered newer.
+func newer(a, b kubeview.KubernetesResource) bool {
+	revisionA, okA := a.Metadata.Revision()
+	revisionB, okB := b.Metadata.Revision()
+	return okA && okB && revisionA > revisionB
+}
+
+// Get returns the resource with the given uid.
+func (s *Store) Get(uid string) (kubeview.KubernetesResource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resource, ok := s.byUID[uid]
+	return resource, ok
+}
+
+// Len returns the number of stored resources.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.byUID)
+}
+
+// FindByName returns the resource of the given kind with the given name in a namespace.
+func (s *Store) FindByName(namespace, kind, name string) (kubeview.KubernetesResource, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	uid, ok := s.byName[nameKey{namespace: namespace, kind: kind, name: name}]
+	if !ok {
+		return kubeview.KubernetesResource{}, false
+	}
+	return s.byUID[uid], true
+}
+
+// FindByLabels returns the resources of a kind in a namespace whose labels match every
+// entry of selector. Following Kubernetes semantics, an empty selector matches nothing.
+func (s *Store) FindByLabels(namespace, kind string, selector map[string]string) []kubeview.KubernetesResource {
+	if len(selector) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Start from the smallest candidate set to keep the intersection cheap.
+	var candidates uidSet
+	for label, value := range selector {
+		set := s.byLabel[labelKey{namespace: namespace, label: label, value: value}]
+		if len(set) == 0 {
+			return nil
+		}
+		if candidates == nil || len(set) < len(candidates) {
+			candidates = set
+		}
+	}
+
+	var matches []kubeview.KubernetesResource
+	for uid := range candidates {
+		resource := s.byUID[uid]
+		if resource.Kind == kind && labelsMatch(resource.Metadata.Labels, selector) {
+			matches = append(matches, resource)
+		}
+	}
+	return matches
+}
+
+// FindOwnedBy returns the resources that list the given uid in their owner references.
+func (s *Store) FindOwnedBy(uid string) []kubeview.KubernetesResource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.collect(s.byOwner[uid])
+}
+
+// List returns the resources of a kind in a namespace.
+func (s *Store) List(namespace, kind string) []kubeview.KubernetesResource {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.collect(s.byKind[kindKey{namespace: namespace, kind: kind}])
+}
+
+func (s *Store) collect(uids uidSet) []kubeview.KubernetesResource {
+	resources := make([]kubeview.KubernetesResource, 0, len(uids))
+	for uid := range uids {
+		resources = append(resources, s.byUID[uid])
+	}
+	return resources
+}
+
+// add indexes a resource. The caller must hold the write lock.
+func (s *Store) add(resource kubeview.KubernetesResource) {
+	uid := resource.Metadata.UID
+	namespace := resource.Metadata.Namespace
+	s.byUID[uid] = resource
+	s.byName[nameKey{namespace: namespace, kind: resource.Kind, name: resource.Metadata.Name}] = uid
+	addToSet(s.byKind, kindKey{namespace: namespace, kind: resource.Kind}, uid)
+	for label, value := range resource.Metadata.Labels {
+		addToSet(s.byLabel, labelKey{namespace: namespace, label: label, value: value}, uid)
+	}
+	for _, owner := range resource.Metadata.OwnerUIDs() {
+		addToSet(s.byOwner, owner, uid)
+	}
+}
+
+// remove drops a resource from every index. The caller must hold the write lock.
+func (s *Store) remove(uid string) (kubeview.KubernetesResource, bool) {
+	resource, ok := s.byUID[uid]
+	if !ok {
+		return kubeview.KubernetesResource{}, false
+	}
+	namespace := resource.Metadata.Namespace
+	delete(s.byUID, uid)
+	key := nameKey{namespace: namespace, kind: resource.Kind, name: resource.Metadata.Name}
+	if s.byName[key] == uid {
+		delete(s.byName, key)
+	}
+	removeFromSet(s.byKind, kindKey{namespace: namespace, kind: resource.Kind}, uid)
+	for label, value := range resource.Metadata.Labels {
+		removeFromSet(s.byLabel, labelKey{namespace: namespace, label: label, value: value}, uid)
+	}
+	for _, owner := range resource.Metadata.OwnerUIDs() {
+		removeFromSet(s.byOwner, owner, uid)
+	}
+	return resource, true
+}
+
+func addToSet[K comparable](index map[K]uidSet, key K, uid string) {
+	set, ok := index[key]
+	if !ok {
+		set = make(uidSet)
+		index[key] = set
+	}
+	set[uid] = struct{}{}
+}
+
+func removeFromSet[K comparable](index map[K]uidSet, key K, uid string) {
+	set, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(set, uid)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
+
+func labelsMatch(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"encoding/json"
+	"testing"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newResource(kind, namespace, name, uid string, labels map[string]string, owners ...string) kubeview.KubernetesResource {
+	resource := kubeview.KubernetesResource{
+		Kind: kind,
+		Metadata: kubeview.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			UID:       uid,
+			Labels:    labels,
+		},
+	}
+	for _, owner := range owners {
+		resource.Metadata.OwnerReferences = append(resource.Metadata.OwnerReferences,
+			json.RawMessage(`{"uid":"`+owner+`"}`))
+	}
+	return resource
+}
+
+func uids(resources []kubeview.KubernetesResource) []string {
+	result := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		result = append(result, resource.Metadata.UID)
+	}
+	return result
+}
+
+func TestStore_Indexes(t *testing.T) {
+	store := NewStore()
+	store.Upsert(newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "web", "tier": "front"}, "rs-1"))
+	store.Upsert(newResource("Pod", "default", "web-2", "pod-2", map[string]string{"app": "web"}, "rs-1"))
+	store.Upsert(newResource("Pod", "other", "web-3", "pod-3", map[string]string{"app": "web"}))
+	store.Upsert(newResource("ConfigMap", "default", "config", "cm-1", nil))
+
+	assert.Equal(t, 4, store.Len())
+
+	resource, ok := store.Get("pod-1")
+	assert.True(t, ok)
+	assert.Equal(t, "web-1", resource.Metadata.Name)
+
+	resource, ok = store.FindByName("default", "ConfigMap", "config")
+	assert.True(t, ok)
+	assert.Equal(t, "cm-1", resource.Metadata.UID)
+	_, ok = store.FindByName("other", "ConfigMap", "config")
+	assert.False(t, ok)
+
+	assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, uids(store.FindByLabels("default", "Pod", map[string]string{"app": "web"})))
+	assert.ElementsMatch(t, []string{"pod-1"}, uids(store.FindByLabels("default", "Pod", map[string]string{"app": "web", "tier": "front"})))
+	assert.Empty(t, store.FindByLabels("default", "Pod", nil))
+	assert.Empty(t, store.FindByLabels("default", "ConfigMap", map[string]string{"app": "web"}))
+
+	assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, uids(store.FindOwnedBy("rs-1")))
+	assert.ElementsMatch(t, []string{"pod-1", "pod-2"}, uids(store.List("default", "Pod")))
+}
+
+func TestStore_UpsertReplacesIndexes(t *testing.T) {
+	store := NewStore()
+	store.Upsert(newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "web"}))
+
+	// Relabelling the pod must drop it from the old label index.
+	store.Upsert(newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "api"}))
+
+	assert.Equal(t, 1, store.Len())
+	assert.Empty(t, store.FindByLabels("default", "Pod", map[string]string{"app": "web"}))
+	assert.ElementsMatch(t, []string{"pod-1"}, uids(store.FindByLabels("default", "Pod", map[string]string{"app": "api"})))
+}
+
+func TestStore_Delete(t *testing.T) {
+	store := NewStore()
+	store.Upsert(newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "web"}, "rs-1"))
+
+	deleted, ok := store.Delete(newResource("Pod", "default", "web-1", "pod-1", nil))
+	assert.True(t, ok)
+	assert.Equal(t, "web-1", deleted.Metadata.Name)
+
+	_, ok = store.Delete(newResource("Pod", "default", "web-1", "pod-1", nil))
+	assert.False(t, ok)
+	assert.Equal(t, 0, store.Len())
+	assert.Empty(t, store.FindOwnedBy("rs-1"))
+	assert.Empty(t, store.List("default", "Pod"))
+	_, ok = store.FindByName("default", "Pod", "web-1")
+	assert.False(t, ok)
+}
+
+func TestStore_ReplaceNamespace(t *testing.T) {
+	store := NewStore()
+	store.Upsert(newResource("Pod", "default", "gone", "pod-gone", nil))
+	store.Upsert(newResource("Pod", "other", "kept", "pod-kept", nil))
+
+	store.ReplaceNamespace("default", []kubeview.KubernetesResource{
+		newResource("Pod", "default", "new", "pod-new", nil),
+	})
+
+	_, ok := store.Get("pod-gone")
+	assert.False(t, ok)
+	_, ok = store.Get("pod-new")
+	assert.True(t, ok)
+	_, ok = store.Get("pod-kept")
+	assert.True(t, ok)
+}
+
+func TestStore_ReplaceMatching(t *testing.T) {
+	store := NewStore()
+	store.Upsert(newResource("Pod", "default", "gone", "pod-gone", nil))
+	store.Upsert(newResource("Service", "default", "untouched", "svc-untouched", nil))
+
+	isPod := func(resource kubeview.KubernetesResource) bool { return resource.Kind == "Pod" }
+	store.ReplaceMatching("default", isPod, []kubeview.KubernetesResource{
+		newResource("Pod", "default", "new", "pod-new", nil),
+	})
+
+	_, ok := store.Get("pod-gone")
+	assert.False(t, ok)
+	_, ok = store.Get("pod-new")
+	assert.True(t, ok)
+	_, ok = store.Get("svc-untouched")
+	assert.True(t, ok)
+}
+
+func TestStore_RejectsStaleVersions(t *testing.T) {
+	store := NewStore()
+	current := newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "web"})
+	current.Metadata.ResourceVersion = "10"
+	stale := newResource("Pod", "default", "web-1", "pod-1", map[string]string{"app": "old"})
+	stale.Metadata.ResourceVersion = "9"
+
+	assert.True(t, store.Upsert(current))
+	assert.False(t, store.Upsert(stale))
+	stored, _ := store.Get("pod-1")
+	assert.Equal(t, "10", stored.Metadata.ResourceVersion)
+
+	// A namespace replace keeps the newer stored version.
+	kept := store.ReplaceNamespace("default", []kubeview.KubernetesResource{stale})
+	assert.Equal(t, []string{"pod-1"}, kept)
+	stored, _ = store.Get("pod-1")
+	assert.Equal(t, "10", stored.Metadata.ResourceVersion)
+	assert.Equal(t, []string{"pod-1"}, uids(store.FindByLabels("default", "Pod", map[string]string{"app": "web"})))
+
+	// Versions that cannot be ordered are always accepted.
+	unordered := newResource("Pod", "default", "web-1", "pod-1", nil)
+	unordered.Metadata.ResourceVersion = "abc"
+	assert.True(t, store.Upsert(unordered))
+}
+
+func TestStore_TombstonesKeepDeletedResourcesOut(t *testing.T) {
+	store := NewStore()
+	snapshot := newResource("Pod", "default", "web-1", "pod-1", nil)
+	snapshot.Metadata.ResourceVersion = "9"
+	deleted := newResource("Pod", "default", "web-1", "pod-1", nil)
+	deleted.Metadata.ResourceVersion = "10"
+
+	// Without tracking, a delete leaves nothing behind.
+	store.Delete(deleted)
+	assert.True(t, store.Upsert(snapshot))
+
+	store.TrackDeletes()
+	store.Delete(deleted)
+	store.ReplaceNamespace("default", []kubeview.KubernetesResource{snapshot})
+	_, ok := store.Get("pod-1")
+	assert.False(t, ok, "a snapshot fetched before the delete does not bring the resource back")
+	assert.False(t, store.Upsert(snapshot))
+	isPod := func(resource kubeview.KubernetesResource) bool { return resource.Kind == "Pod" }
+	store.ReplaceMatching("default", isPod, []kubeview.KubernetesResource{snapshot})
+	assert.Equal(t, 0, store.Len())
+
+	store.ForgetDeletes()
+	assert.True(t, store.Upsert(snapshot))
+}
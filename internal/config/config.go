@@ -3,34 +3,314 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// Config holds all configuration for the service.
+// DefaultNeo4jBatchSize is the number of rows sent in a single UNWIND query when none is configured.
+const DefaultNeo4jBatchSize = 500
+
+// DefaultNeo4jMaxConnectionPoolSize is the Neo4j driver's own default connection pool size.
+const DefaultNeo4jMaxConnectionPoolSize = 100
+
+// Config holds all configuration for the service. Each setting is read from the YAML file under the key in
+// its yaml tag, then from the environment variable of the same name in upper case, and then from a flag
+// where one exists. Settings tagged reload can be changed while the service runs; secrets are redacted
+// whenever the configuration is printed or logged.
 type Config struct {
-	KubeviewURL          string
-	Neo4jURI             string
-	Neo4jUser            string
-	Neo4jPassword        string
-	ClientID             string
-	OtelExporterEndpoint string
+	// Address the HTTP server listens on.
+	ListenAddress string `yaml:"listen_address"`
+
+	KubeviewURL      string `yaml:"kubeview_url" reload:"true"`
+	Neo4jURI         string `yaml:"neo4j_uri" reload:"true"`
+	Neo4jUser        string `yaml:"neo4j_user" reload:"true"`
+	Neo4jPassword    string `yaml:"neo4j_password" reload:"true" secret:"true"`
+	Neo4jBatchSize   int    `yaml:"neo4j_batch_size"`
+	Neo4jMaxPoolSize int    `yaml:"neo4j_max_connection_pool_size" reload:"true"`
+	ClientID         string `yaml:"client_id" reload:"true"`
+	// OTLP collector address, either host:port or a URL. Empty leaves it to the exporter, which
+	// reads the signal-specific OTEL_EXPORTER_OTLP_*_ENDPOINT variables and defaults to localhost.
+	OtelExporterEndpoint string `yaml:"otel_exporter_otlp_endpoint"`
+
+	// Telemetry exporters for traces and metrics: "otlp-grpc", "otlp-http", "stdout" or "none".
+	TraceExporter  string `yaml:"otel_traces_exporter"`
+	MetricExporter string `yaml:"otel_metrics_exporter"`
+	// Whether OTLP is sent in plain text and, if not, the certificates used for TLS.
+	OtelExporterInsecure          bool   `yaml:"otel_exporter_otlp_insecure"`
+	OtelExporterCertificate       string `yaml:"otel_exporter_otlp_certificate"`
+	OtelExporterClientCertificate string `yaml:"otel_exporter_otlp_client_certificate"`
+	OtelExporterClientKey         string `yaml:"otel_exporter_otlp_client_key"`
+	// Trace sampler, named as in OTEL_TRACES_SAMPLER, and the ratio of the ratio-based samplers.
+	TraceSampler      string  `yaml:"otel_traces_sampler"`
+	TraceSamplerRatio float64 `yaml:"otel_traces_sampler_arg"`
+	// Resource attributes added to all telemetry, besides those in OTEL_RESOURCE_ATTRIBUTES.
+	ClusterName    string `yaml:"cluster_name"`
+	ServiceVersion string `yaml:"service_version"`
+
+	// Timeout of each request to KubeView, and retry policy and circuit breaker for those requests.
+	KubeviewTimeout           time.Duration `yaml:"kubeview_timeout"`
+	KubeviewRetryMaxAttempts  int           `yaml:"kubeview_retry_max_attempts"`
+	KubeviewRetryInitialDelay time.Duration `yaml:"kubeview_retry_initial_delay"`
+	KubeviewRetryMaxDelay     time.Duration `yaml:"kubeview_retry_max_delay"`
+	KubeviewRetryMultiplier   float64       `yaml:"kubeview_retry_multiplier"`
+	KubeviewRetryJitter       float64       `yaml:"kubeview_retry_jitter"`
+	KubeviewBreakerThreshold  int           `yaml:"kubeview_breaker_threshold"`
+	KubeviewBreakerCooldown   time.Duration `yaml:"kubeview_breaker_cooldown"`
+
+	// Attempts made to sync a namespace before it is reported as failed. Retries are
+	// spaced like KubeView requests.
+	SyncNamespaceMaxAttempts int `yaml:"sync_namespace_max_attempts"`
+
+	// Namespaces fetched from KubeView and written to Neo4j at once during a sync.
+	SyncFetchConcurrency int `yaml:"sync_fetch_concurrency"`
+	SyncWriteConcurrency int `yaml:"sync_write_concurrency"`
+
+	// Interval between periodic resyncs, which measure and fix drift between KubeView
+	// and the graph, and whether they rewrite every namespace ("full") or only those
+	// that drifted ("incremental"). Periodic resyncs are disabled when the interval is zero.
+	ResyncInterval time.Duration `yaml:"resync_interval"`
+	ResyncMode     string        `yaml:"resync_mode"`
+
+	// Namespaces kept in the graph, as glob patterns such as "kube-*". An empty include list keeps
+	// every namespace that is not excluded. Cluster-scoped resources are always kept.
+	IncludeNamespaces []string `yaml:"include_namespaces" reload:"true"`
+	ExcludeNamespaces []string `yaml:"exclude_namespaces" reload:"true"`
+
+	// Workers applying SSE events to the graph, the capacity of each worker's queue,
+	// how long each worker coalesces events before writing them and how many events
+	// are buffered during the initial sync.
+	EventWorkers          int           `yaml:"event_workers"`
+	EventQueueSize        int           `yaml:"event_queue_size"`
+	EventCoalesceWindow   time.Duration `yaml:"event_coalesce_window"`
+	EventReplayBufferSize int           `yaml:"event_replay_buffer_size"`
+
+	// How long /readyz reuses the result of a dependency check, and how long queued
+	// events may wait without the event workers making progress before /livez fails.
+	ReadinessCacheTTL    time.Duration `yaml:"readiness_cache_ttl"`
+	LivenessStallTimeout time.Duration `yaml:"liveness_stall_timeout"`
+
+	// How long the service waits for work in progress to finish when shutting down.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// Minimum level of the records logged ("debug", "info", "warn" or "error"), which can be
+	// changed at runtime through the API, and whether they are written as "json" or "text".
+	LogLevel  string `yaml:"log_level" reload:"true"`
+	LogFormat string `yaml:"log_format"`
+
+	// PrintConfig is set by the --print-config flag: the service prints its configuration and exits.
+	PrintConfig bool `yaml:"-"`
+	// args are the command-line arguments the configuration was loaded from, which Reload reads again.
+	args []string
+	// envProblems lists the environment variables whose value could not be parsed, reported by Validate.
+	envProblems []string
 }
 
-// LoadConfig loads configuration from environment variables.
-func LoadConfig() *Config {
-	otelEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otelEndpoint == "" {
-		otelEndpoint = "localhost:4317"
+// defaults returns the configuration used for every setting that is not set.
+func defaults() *Config {
+	return &Config{
+		ListenAddress:             ":8080",
+		Neo4jBatchSize:            DefaultNeo4jBatchSize,
+		Neo4jMaxPoolSize:          DefaultNeo4jMaxConnectionPoolSize,
+		ClientID:                  fmt.Sprintf("Client-%d", os.Getpid()),
+		TraceExporter:             "otlp-grpc",
+		MetricExporter:            "otlp-grpc",
+		TraceSampler:              "parentbased_always_on",
+		TraceSamplerRatio:         1,
+		KubeviewTimeout:           30 * time.Second,
+		KubeviewRetryMaxAttempts:  4,
+		KubeviewRetryInitialDelay: 500 * time.Millisecond,
+		KubeviewRetryMaxDelay:     30 * time.Second,
+		KubeviewRetryMultiplier:   2,
+		KubeviewRetryJitter:       0.2,
+		KubeviewBreakerThreshold:  5,
+		KubeviewBreakerCooldown:   30 * time.Second,
+		SyncNamespaceMaxAttempts:  3,
+		SyncFetchConcurrency:      4,
+		SyncWriteConcurrency:      2,
+		ResyncMode:                "incremental",
+		EventWorkers:              4,
+		EventQueueSize:            256,
+		EventReplayBufferSize:     10000,
+		ReadinessCacheTTL:         10 * time.Second,
+		LivenessStallTimeout:      2 * time.Minute,
+		ShutdownTimeout:           10 * time.Second,
+		LogLevel:                  "info",
+		LogFormat:                 "json",
 	}
-	clientId := os.Getenv("CLIENT_ID")
-	if clientId == "" {
-		clientId = fmt.Sprintf("Client-%d", os.Getpid())
+}
+
+// applyEnv overrides the settings whose environment variable is set. Values that cannot be parsed are
+// kept for Validate to report; whether the others are in range is also left to Validate.
+func (c *Config) applyEnv() {
+	env := &envReader{}
+	c.ListenAddress = env.string("LISTEN_ADDRESS", c.ListenAddress)
+	c.KubeviewURL = env.string("KUBEVIEW_URL", c.KubeviewURL)
+	c.Neo4jURI = env.string("NEO4J_URI", c.Neo4jURI)
+	c.Neo4jUser = env.string("NEO4J_USER", c.Neo4jUser)
+	c.Neo4jPassword = env.string("NEO4J_PASSWORD", c.Neo4jPassword)
+	c.Neo4jBatchSize = env.int("NEO4J_BATCH_SIZE", c.Neo4jBatchSize)
+	c.Neo4jMaxPoolSize = env.int("NEO4J_MAX_CONNECTION_POOL_SIZE", c.Neo4jMaxPoolSize)
+	c.ClientID = env.string("CLIENT_ID", c.ClientID)
+	c.OtelExporterEndpoint = env.string("OTEL_EXPORTER_OTLP_ENDPOINT", c.OtelExporterEndpoint)
+
+	c.TraceExporter = env.exporter("TRACES", c.TraceExporter)
+	c.MetricExporter = env.exporter("METRICS", c.MetricExporter)
+	c.OtelExporterInsecure = env.bool("OTEL_EXPORTER_OTLP_INSECURE", c.OtelExporterInsecure)
+	c.OtelExporterCertificate = env.string("OTEL_EXPORTER_OTLP_CERTIFICATE", c.OtelExporterCertificate)
+	c.OtelExporterClientCertificate = env.string("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE",
+		c.OtelExporterClientCertificate)
+	c.OtelExporterClientKey = env.string("OTEL_EXPORTER_OTLP_CLIENT_KEY", c.OtelExporterClientKey)
+	c.TraceSampler = env.string("OTEL_TRACES_SAMPLER", c.TraceSampler)
+	c.TraceSamplerRatio = env.float("OTEL_TRACES_SAMPLER_ARG", c.TraceSamplerRatio)
+	c.ClusterName = env.string("CLUSTER_NAME", c.ClusterName)
+	c.ServiceVersion = env.string("SERVICE_VERSION", c.ServiceVersion)
+
+	c.KubeviewTimeout = env.duration("KUBEVIEW_TIMEOUT", c.KubeviewTimeout)
+	c.KubeviewRetryMaxAttempts = env.int("KUBEVIEW_RETRY_MAX_ATTEMPTS", c.KubeviewRetryMaxAttempts)
+	c.KubeviewRetryInitialDelay = env.duration("KUBEVIEW_RETRY_INITIAL_DELAY", c.KubeviewRetryInitialDelay)
+	c.KubeviewRetryMaxDelay = env.duration("KUBEVIEW_RETRY_MAX_DELAY", c.KubeviewRetryMaxDelay)
+	c.KubeviewRetryMultiplier = env.float("KUBEVIEW_RETRY_MULTIPLIER", c.KubeviewRetryMultiplier)
+	c.KubeviewRetryJitter = env.float("KUBEVIEW_RETRY_JITTER", c.KubeviewRetryJitter)
+	c.KubeviewBreakerThreshold = env.int("KUBEVIEW_BREAKER_THRESHOLD", c.KubeviewBreakerThreshold)
+	c.KubeviewBreakerCooldown = env.duration("KUBEVIEW_BREAKER_COOLDOWN", c.KubeviewBreakerCooldown)
+	c.SyncNamespaceMaxAttempts = env.int("SYNC_NAMESPACE_MAX_ATTEMPTS", c.SyncNamespaceMaxAttempts)
+	c.SyncFetchConcurrency = env.int("SYNC_FETCH_CONCURRENCY", c.SyncFetchConcurrency)
+	c.SyncWriteConcurrency = env.int("SYNC_WRITE_CONCURRENCY", c.SyncWriteConcurrency)
+	c.ResyncInterval = env.duration("RESYNC_INTERVAL", c.ResyncInterval)
+	c.ResyncMode = env.string("RESYNC_MODE", c.ResyncMode)
+	c.IncludeNamespaces = env.list("INCLUDE_NAMESPACES", c.IncludeNamespaces)
+	c.ExcludeNamespaces = env.list("EXCLUDE_NAMESPACES", c.ExcludeNamespaces)
+	c.EventWorkers = env.int("EVENT_WORKERS", c.EventWorkers)
+	c.EventQueueSize = env.int("EVENT_QUEUE_SIZE", c.EventQueueSize)
+	c.EventCoalesceWindow = env.duration("EVENT_COALESCE_WINDOW", c.EventCoalesceWindow)
+	c.EventReplayBufferSize = env.int("EVENT_REPLAY_BUFFER_SIZE", c.EventReplayBufferSize)
+	c.ReadinessCacheTTL = env.duration("READINESS_CACHE_TTL", c.ReadinessCacheTTL)
+	c.LivenessStallTimeout = env.duration("LIVENESS_STALL_TIMEOUT", c.LivenessStallTimeout)
+	c.ShutdownTimeout = env.duration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	c.LogLevel = env.string("LOG_LEVEL", c.LogLevel)
+	c.LogFormat = env.string("LOG_FORMAT", c.LogFormat)
+	c.envProblems = env.problems
+}
+
+// envReader reads settings from the environment, collecting the values that cannot be parsed. A setting
+// whose variable is unset or cannot be parsed keeps its current value.
+type envReader struct {
+	problems []string
+}
+
+func (r *envReader) invalid(key, raw, kind string) {
+	r.problems = append(r.problems, fmt.Sprintf("%s %q is not %s", key, raw, kind))
+}
+
+func (r *envReader) string(key, def string) string {
+	if raw := os.Getenv(key); raw != "" {
+		return raw
 	}
-	return &Config{
-		KubeviewURL:          os.Getenv("KUBEVIEW_URL"),
-		Neo4jURI:             os.Getenv("NEO4J_URI"),
-		Neo4jUser:            os.Getenv("NEO4J_USER"),
-		Neo4jPassword:        os.Getenv("NEO4J_PASSWORD"),
-		ClientID:             clientId,
-		OtelExporterEndpoint: otelEndpoint,
+	return def
+}
+
+// list reads a comma-separated list.
+func (r *envReader) list(key string, def []string) []string {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (r *envReader) int(key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		r.invalid(key, raw, "an integer")
+		return def
+	}
+	return value
+}
+
+// duration reads a duration such as "30s".
+func (r *envReader) duration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		r.invalid(key, raw, "a duration")
+		return def
+	}
+	return value
+}
+
+func (r *envReader) float(key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		r.invalid(key, raw, "a number")
+		return def
+	}
+	return value
+}
+
+// bool reads a boolean such as "true" or "false".
+func (r *envReader) bool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		r.invalid(key, raw, "a boolean")
+		return def
+	}
+	return value
+}
+
+// exporter reads the exporter for a signal ("TRACES" or "METRICS") from OTEL_<signal>_EXPORTER. Besides our
+// own names it accepts the standard "otlp", whose transport is then chosen by OTEL_EXPORTER_OTLP_<signal>_PROTOCOL
+// or OTEL_EXPORTER_OTLP_PROTOCOL, and "console" for stdout. The protocol variables also apply when def is an
+// OTLP exporter. Other names are kept for Validate to report.
+func (r *envReader) exporter(signal, def string) string {
+	switch raw := os.Getenv("OTEL_" + signal + "_EXPORTER"); raw {
+	case "":
+		if def != "otlp-grpc" && def != "otlp-http" {
+			return def
+		}
+		return otlpProtocol(signal, def)
+	case "otlp":
+		return otlpProtocol(signal, "otlp-grpc")
+	case "console":
+		return "stdout"
+	default:
+		return raw
+	}
+}
+
+// otlpProtocol returns the OTLP exporter for the protocol set for a signal, or def when none is set.
+func otlpProtocol(signal, def string) string {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	switch protocol {
+	case "http/protobuf":
+		return "otlp-http"
+	case "grpc":
+		return "otlp-grpc"
+	default:
+		return def
 	}
 }
@@ -1,11 +1,20 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
-func TestLoadConfig(t *testing.T) {
+func TestLoad_Env(t *testing.T) {
 	// Arrange
 	if err := os.Setenv("KUBEVIEW_URL", "http://localhost:8080"); err != nil {
 		t.Fatalf("failed to set KUBEVIEW_URL: %v", err)
@@ -27,7 +36,7 @@ func TestLoadConfig(t *testing.T) {
 	}
 
 	// Act
-	cfg := LoadConfig()
+	cfg := mustLoad(t, nil)
 
 	// Assert
 	if cfg.KubeviewURL != "http://localhost:8080" {
@@ -49,3 +58,534 @@ func TestLoadConfig(t *testing.T) {
 		t.Errorf("expected OtelExporterEndpoint to be 'otel.example.com:4317', got '%s'", cfg.OtelExporterEndpoint)
 	}
 }
+
+func TestLoad_BatchSize(t *testing.T) {
+	t.Run("defaults when unset", func(t *testing.T) {
+		t.Setenv("NEO4J_BATCH_SIZE", "")
+
+		cfg := mustLoad(t, nil)
+
+		if cfg.Neo4jBatchSize != DefaultNeo4jBatchSize {
+			t.Errorf("expected Neo4jBatchSize to be %d, got %d", DefaultNeo4jBatchSize, cfg.Neo4jBatchSize)
+		}
+	})
+
+	t.Run("reads a valid value", func(t *testing.T) {
+		t.Setenv("NEO4J_BATCH_SIZE", "250")
+
+		cfg := mustLoad(t, nil)
+
+		if cfg.Neo4jBatchSize != 250 {
+			t.Errorf("expected Neo4jBatchSize to be 250, got %d", cfg.Neo4jBatchSize)
+		}
+	})
+
+	t.Run("reports invalid values", func(t *testing.T) {
+		t.Setenv("NEO4J_BATCH_SIZE", "-1")
+
+		cfg := mustLoad(t, nil)
+
+		expectProblems(t, cfg, "neo4j_batch_size must be positive, got -1")
+	})
+
+	t.Run("reports values that are not integers", func(t *testing.T) {
+		t.Setenv("NEO4J_BATCH_SIZE", "lots")
+
+		cfg := mustLoad(t, nil)
+
+		expectProblems(t, cfg, `NEO4J_BATCH_SIZE "lots" is not an integer`)
+	})
+}
+
+func TestLoad_KubeviewRetry(t *testing.T) {
+	t.Setenv("KUBEVIEW_RETRY_MAX_ATTEMPTS", "6")
+	t.Setenv("KUBEVIEW_RETRY_INITIAL_DELAY", "250ms")
+	t.Setenv("KUBEVIEW_RETRY_MAX_DELAY", "not-a-duration")
+	t.Setenv("KUBEVIEW_RETRY_JITTER", "0.5")
+	t.Setenv("KUBEVIEW_BREAKER_THRESHOLD", "0")
+	t.Setenv("KUBEVIEW_BREAKER_COOLDOWN", "1m")
+	t.Setenv("SYNC_NAMESPACE_MAX_ATTEMPTS", "2")
+
+	cfg := mustLoad(t, nil)
+
+	if cfg.KubeviewRetryMaxAttempts != 6 {
+		t.Errorf("expected KubeviewRetryMaxAttempts to be 6, got %d", cfg.KubeviewRetryMaxAttempts)
+	}
+	if cfg.KubeviewRetryInitialDelay != 250*time.Millisecond {
+		t.Errorf("expected KubeviewRetryInitialDelay to be 250ms, got %s", cfg.KubeviewRetryInitialDelay)
+	}
+	expectProblems(t, cfg, `KUBEVIEW_RETRY_MAX_DELAY "not-a-duration" is not a duration`)
+	if cfg.KubeviewRetryJitter != 0.5 {
+		t.Errorf("expected KubeviewRetryJitter to be 0.5, got %f", cfg.KubeviewRetryJitter)
+	}
+	if cfg.KubeviewBreakerThreshold != 0 {
+		t.Errorf("expected KubeviewBreakerThreshold to be 0, got %d", cfg.KubeviewBreakerThreshold)
+	}
+	if cfg.KubeviewBreakerCooldown != time.Minute {
+		t.Errorf("expected KubeviewBreakerCooldown to be 1m, got %s", cfg.KubeviewBreakerCooldown)
+	}
+	if cfg.SyncNamespaceMaxAttempts != 2 {
+		t.Errorf("expected SyncNamespaceMaxAttempts to be 2, got %d", cfg.SyncNamespaceMaxAttempts)
+	}
+}
+
+func TestLoad_SyncConcurrency(t *testing.T) {
+	t.Setenv("SYNC_FETCH_CONCURRENCY", "8")
+	t.Setenv("SYNC_WRITE_CONCURRENCY", "-2")
+	t.Setenv("NEO4J_MAX_CONNECTION_POOL_SIZE", "20")
+
+	cfg := mustLoad(t, nil)
+
+	if cfg.SyncFetchConcurrency != 8 {
+		t.Errorf("expected SyncFetchConcurrency to be 8, got %d", cfg.SyncFetchConcurrency)
+	}
+	expectProblems(t, cfg, "sync_write_concurrency must be positive, got -2")
+	if cfg.Neo4jMaxPoolSize != 20 {
+		t.Errorf("expected Neo4jMaxPoolSize to be 20, got %d", cfg.Neo4jMaxPoolSize)
+	}
+}
+
+func TestLoad_EventWorkers(t *testing.T) {
+	t.Setenv("EVENT_WORKERS", "8")
+	t.Setenv("EVENT_QUEUE_SIZE", "0")
+	t.Setenv("EVENT_COALESCE_WINDOW", "250ms")
+	t.Setenv("EVENT_REPLAY_BUFFER_SIZE", "500")
+
+	cfg := mustLoad(t, nil)
+
+	if cfg.EventWorkers != 8 {
+		t.Errorf("expected EventWorkers to be 8, got %d", cfg.EventWorkers)
+	}
+	expectProblems(t, cfg, "event_queue_size must be positive, got 0")
+	if cfg.EventCoalesceWindow != 250*time.Millisecond {
+		t.Errorf("expected EventCoalesceWindow to be 250ms, got %s", cfg.EventCoalesceWindow)
+	}
+	if cfg.EventReplayBufferSize != 500 {
+		t.Errorf("expected EventReplayBufferSize to be 500, got %d", cfg.EventReplayBufferSize)
+	}
+}
+
+func TestLoad_Resync(t *testing.T) {
+	t.Setenv("RESYNC_INTERVAL", "15m")
+	t.Setenv("RESYNC_MODE", "sometimes")
+
+	cfg := mustLoad(t, nil)
+
+	if cfg.ResyncInterval != 15*time.Minute {
+		t.Errorf("expected ResyncInterval to be 15m, got %s", cfg.ResyncInterval)
+	}
+	expectProblems(t, cfg, `resync_mode must be one of full, incremental, got "sometimes"`)
+}
+
+func TestLoad_ZeroDisables(t *testing.T) {
+	file := writeConfigFile(t, "resync_interval: 30m\nevent_coalesce_window: 500ms\n")
+	t.Setenv("RESYNC_INTERVAL", "0")
+	t.Setenv("EVENT_COALESCE_WINDOW", "0s")
+
+	cfg, err := Load([]string{"--config", file})
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+
+	if cfg.ResyncInterval != 0 || cfg.EventCoalesceWindow != 0 {
+		t.Errorf("expected the environment to disable the file's settings, got %s and %s",
+			cfg.ResyncInterval, cfg.EventCoalesceWindow)
+	}
+}
+
+func TestLoad_Probes(t *testing.T) {
+	cfg := mustLoad(t, nil)
+	if cfg.ReadinessCacheTTL != 10*time.Second {
+		t.Errorf("expected default ReadinessCacheTTL to be 10s, got %s", cfg.ReadinessCacheTTL)
+	}
+	if cfg.LivenessStallTimeout != 2*time.Minute {
+		t.Errorf("expected default LivenessStallTimeout to be 2m, got %s", cfg.LivenessStallTimeout)
+	}
+
+	t.Setenv("READINESS_CACHE_TTL", "30s")
+	t.Setenv("LIVENESS_STALL_TIMEOUT", "5m")
+	cfg = mustLoad(t, nil)
+	if cfg.ReadinessCacheTTL != 30*time.Second {
+		t.Errorf("expected ReadinessCacheTTL to be 30s, got %s", cfg.ReadinessCacheTTL)
+	}
+	if cfg.LivenessStallTimeout != 5*time.Minute {
+		t.Errorf("expected LivenessStallTimeout to be 5m, got %s", cfg.LivenessStallTimeout)
+	}
+}
+
+func TestLoad_Telemetry(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := mustLoad(t, nil)
+
+		if cfg.TraceExporter != "otlp-grpc" || cfg.MetricExporter != "otlp-grpc" {
+			t.Errorf("expected otlp-grpc exporters, got %s and %s", cfg.TraceExporter, cfg.MetricExporter)
+		}
+		if cfg.OtelExporterInsecure {
+			t.Error("expected OtelExporterInsecure to default to false")
+		}
+		if cfg.TraceSampler != "parentbased_always_on" || cfg.TraceSamplerRatio != 1 {
+			t.Errorf("expected parentbased_always_on with ratio 1, got %s with %v", cfg.TraceSampler, cfg.TraceSamplerRatio)
+		}
+	})
+
+	t.Run("reads the standard variables", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+		t.Setenv("OTEL_METRICS_EXPORTER", "console")
+		t.Setenv("OTEL_EXPORTER_OTLP_INSECURE", "true")
+		t.Setenv("OTEL_EXPORTER_OTLP_CERTIFICATE", "/etc/otel/ca.pem")
+		t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_traceidratio")
+		t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+		t.Setenv("CLUSTER_NAME", "prod-eu")
+
+		cfg := mustLoad(t, nil)
+
+		if cfg.TraceExporter != "otlp-http" {
+			t.Errorf("expected TraceExporter to be otlp-http, got %s", cfg.TraceExporter)
+		}
+		if cfg.MetricExporter != "stdout" {
+			t.Errorf("expected MetricExporter to be stdout, got %s", cfg.MetricExporter)
+		}
+		if !cfg.OtelExporterInsecure {
+			t.Error("expected OtelExporterInsecure to be true")
+		}
+		if cfg.OtelExporterCertificate != "/etc/otel/ca.pem" {
+			t.Errorf("expected OtelExporterCertificate to be /etc/otel/ca.pem, got %s", cfg.OtelExporterCertificate)
+		}
+		if cfg.TraceSampler != "parentbased_traceidratio" || cfg.TraceSamplerRatio != 0.25 {
+			t.Errorf("expected parentbased_traceidratio with ratio 0.25, got %s with %v",
+				cfg.TraceSampler, cfg.TraceSamplerRatio)
+		}
+		if cfg.ClusterName != "prod-eu" {
+			t.Errorf("expected ClusterName to be prod-eu, got %s", cfg.ClusterName)
+		}
+	})
+
+	t.Run("accepts our exporter names and reports unknown ones", func(t *testing.T) {
+		t.Setenv("OTEL_TRACES_EXPORTER", "none")
+		t.Setenv("OTEL_METRICS_EXPORTER", "zipkin")
+
+		cfg := mustLoad(t, nil)
+
+		if cfg.TraceExporter != "none" {
+			t.Errorf("expected TraceExporter to be none, got %s", cfg.TraceExporter)
+		}
+		expectProblems(t, cfg, `otel_metrics_exporter must be one of otlp-grpc, otlp-http, stdout, none, got "zipkin"`)
+	})
+}
+
+func TestLoad_Logging(t *testing.T) {
+	cfg := mustLoad(t, nil)
+	if cfg.LogLevel != "info" || cfg.LogFormat != "json" {
+		t.Errorf("expected info level in json, got %s in %s", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("LOG_FORMAT", "text")
+	cfg = mustLoad(t, nil)
+	if cfg.LogLevel != "debug" || cfg.LogFormat != "text" {
+		t.Errorf("expected debug level in text, got %s in %s", cfg.LogLevel, cfg.LogFormat)
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LOG_FORMAT", "xml")
+	cfg = mustLoad(t, nil)
+	expectProblems(t, cfg, `log_level must be one of debug, info, warn, error, got "verbose"`,
+		`log_format must be one of json, text, got "xml"`)
+}
+
+// expectProblems checks that validating cfg reports each of the problems.
+// mustLoad loads the configuration from args, the environment and the file they name, if any.
+func mustLoad(t *testing.T, args []string) *Config {
+	t.Helper()
+	cfg, err := Load(args)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	return cfg
+}
+
+func expectProblems(t *testing.T, cfg *Config, problems ...string) {
+	t.Helper()
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, problem := range problems {
+		if !slices.Contains(validationErr.Problems, problem) {
+			t.Errorf("expected problem %q, got %v", problem, validationErr.Problems)
+		}
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "kubeview_url: http://file:8000\nneo4j_user: file-user\nneo4j_batch_size: 100\nevent_workers: 2\n" +
+		"kubeview_timeout: 5s\nexclude_namespaces: [kube-*]\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("KUBEVIEW_URL", "")
+	t.Setenv("NEO4J_USER", "env-user")
+	t.Setenv("NEO4J_BATCH_SIZE", "200")
+	t.Setenv("EVENT_WORKERS", "")
+
+	cfg, err := Load([]string{"--neo4j-batch-size", "300", "--include-namespaces", "shop, web-*"})
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+
+	if cfg.KubeviewURL != "http://file:8000" || cfg.EventWorkers != 2 || cfg.KubeviewTimeout != 5*time.Second {
+		t.Errorf("expected settings from the file, got %s, %d workers and %s timeout",
+			cfg.KubeviewURL, cfg.EventWorkers, cfg.KubeviewTimeout)
+	}
+	if cfg.Neo4jUser != "env-user" {
+		t.Errorf("expected the environment to override the file, got %s", cfg.Neo4jUser)
+	}
+	if cfg.Neo4jBatchSize != 300 {
+		t.Errorf("expected the flag to override the environment, got %d", cfg.Neo4jBatchSize)
+	}
+	if !slices.Equal(cfg.IncludeNamespaces, []string{"shop", "web-*"}) ||
+		!slices.Equal(cfg.ExcludeNamespaces, []string{"kube-*"}) {
+		t.Errorf("expected namespace filters, got %v and %v", cfg.IncludeNamespaces, cfg.ExcludeNamespaces)
+	}
+
+	if err := os.WriteFile(file, []byte("kubeview_url: http://reloaded:8000\n"), 0o600); err != nil {
+		t.Fatalf("failed to rewrite config file: %v", err)
+	}
+	reloaded, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("failed to reload configuration: %v", err)
+	}
+	if reloaded.KubeviewURL != "http://reloaded:8000" || reloaded.Neo4jBatchSize != 300 {
+		t.Errorf("expected reload to read the file and keep the flags, got %s and %d",
+			reloaded.KubeviewURL, reloaded.Neo4jBatchSize)
+	}
+}
+
+// writeConfigFile writes a config file with content and returns its path.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return file
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		get  func(*Config) any
+		want any
+	}{
+		{
+			name: "file overrides the default",
+			file: "kubeview_timeout: 5s\n",
+			get:  func(c *Config) any { return c.KubeviewTimeout },
+			want: 5 * time.Second,
+		},
+		{
+			name: "environment overrides a duration from the file",
+			file: "kubeview_timeout: 5s\n",
+			env:  map[string]string{"KUBEVIEW_TIMEOUT": "10s"},
+			get:  func(c *Config) any { return c.KubeviewTimeout },
+			want: 10 * time.Second,
+		},
+		{
+			name: "flag overrides a duration from the environment",
+			file: "kubeview_timeout: 5s\n",
+			env:  map[string]string{"KUBEVIEW_TIMEOUT": "10s"},
+			args: []string{"--kubeview-timeout", "15s"},
+			get:  func(c *Config) any { return c.KubeviewTimeout },
+			want: 15 * time.Second,
+		},
+		{
+			name: "environment overrides a list from the file",
+			file: "include_namespaces: [shop]\n",
+			env:  map[string]string{"INCLUDE_NAMESPACES": "web, api"},
+			get:  func(c *Config) any { return c.IncludeNamespaces },
+			want: []string{"web", "api"},
+		},
+		{
+			name: "flag overrides a list from the environment",
+			file: "include_namespaces: [shop]\n",
+			env:  map[string]string{"INCLUDE_NAMESPACES": "web, api"},
+			args: []string{"--include-namespaces", "batch"},
+			get:  func(c *Config) any { return c.IncludeNamespaces },
+			want: []string{"batch"},
+		},
+		{
+			name: "environment overrides a string from the file",
+			file: "resync_mode: full\n",
+			env:  map[string]string{"RESYNC_MODE": "incremental"},
+			get:  func(c *Config) any { return c.ResyncMode },
+			want: "incremental",
+		},
+		{
+			name: "flag overrides a string from the environment",
+			file: "resync_mode: incremental\n",
+			env:  map[string]string{"RESYNC_MODE": "incremental"},
+			args: []string{"--resync-mode", "full"},
+			get:  func(c *Config) any { return c.ResyncMode },
+			want: "full",
+		},
+		{
+			name: "environment overrides a boolean from the file",
+			file: "otel_exporter_otlp_insecure: true\n",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_INSECURE": "false"},
+			get:  func(c *Config) any { return c.OtelExporterInsecure },
+			want: false,
+		},
+		{
+			name: "empty environment variable keeps the file",
+			file: "neo4j_batch_size: 100\n",
+			env:  map[string]string{"NEO4J_BATCH_SIZE": ""},
+			get:  func(c *Config) any { return c.Neo4jBatchSize },
+			want: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", writeConfigFile(t, tt.file))
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := mustLoad(t, tt.args)
+
+			if got := tt.get(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfig_ReloadRereadsEnvironment(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "log_level: warn\nresync_mode: full\n"))
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("RESYNC_MODE", "")
+	cfg := mustLoad(t, []string{"--resync-mode", "incremental"})
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("RESYNC_MODE", "full")
+	reloaded, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("failed to reload configuration: %v", err)
+	}
+
+	if reloaded.LogLevel != "debug" {
+		t.Errorf("expected reload to read the environment over the file, got %s", reloaded.LogLevel)
+	}
+	if reloaded.ResyncMode != "incremental" {
+		t.Errorf("expected the flag to still override the environment, got %s", reloaded.ResyncMode)
+	}
+}
+
+func TestLoad_UnknownSetting(t *testing.T) {
+	file := writeConfigFile(t, "neo4j_batchsize: 100\n")
+
+	_, err := Load([]string{"--config", file})
+
+	if err == nil || !strings.Contains(err.Error(), "neo4j_batchsize") {
+		t.Errorf("expected an error naming the unknown setting, got %v", err)
+	}
+}
+
+func TestConfig_WriteYAML(t *testing.T) {
+	t.Setenv("NEO4J_PASSWORD", "s3cret")
+	cfg, err := Load([]string{"--print-config", "--kubeview-timeout", "45s"})
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	if !cfg.PrintConfig {
+		t.Error("expected --print-config to be set")
+	}
+
+	var out bytes.Buffer
+	if err := cfg.WriteYAML(&out); err != nil {
+		t.Fatalf("failed to write configuration: %v", err)
+	}
+
+	if strings.Contains(out.String(), "s3cret") || !strings.Contains(out.String(), "neo4j_password: REDACTED") {
+		t.Errorf("expected the password to be redacted, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "kubeview_timeout: 45s") {
+		t.Errorf("expected durations to be written as strings, got:\n%s", out.String())
+	}
+	if cfg.Neo4jPassword != "s3cret" {
+		t.Error("expected writing the configuration to leave the password unchanged")
+	}
+
+	var written Config
+	if err := yaml.Unmarshal(out.Bytes(), &written); err != nil {
+		t.Errorf("expected the output to read back as a config file: %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := defaults()
+	cfg.KubeviewURL = "http://kubeview:8000"
+	cfg.Neo4jURI = "neo4j://neo4j:7687"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected the defaults to be valid, got %v", err)
+	}
+	cfg.KubeviewBreakerThreshold = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a zero breaker threshold to disable the breaker, got %v", err)
+	}
+
+	cfg.ListenAddress = "8080"
+	cfg.Neo4jURI = "http://neo4j:7474"
+	cfg.EventWorkers = 0
+	cfg.KubeviewRetryMaxDelay = time.Millisecond
+	cfg.ResyncMode = "partial"
+	cfg.ExcludeNamespaces = []string{"kube-["}
+	err := cfg.Validate()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, setting := range []string{"listen_address", "neo4j_uri", "event_workers", "kubeview_retry_max_delay",
+		"resync_mode", "exclude_namespaces"} {
+		if !strings.Contains(err.Error(), setting) {
+			t.Errorf("expected a problem with %s, got %v", setting, err)
+		}
+	}
+	if len(validationErr.Problems) != 6 {
+		t.Errorf("expected 6 problems, got %d: %v", len(validationErr.Problems), validationErr.Problems)
+	}
+}
+
+func TestConfig_Diff(t *testing.T) {
+	current := defaults()
+	current.Neo4jPassword = "old-secret"
+	next := *current
+	next.Neo4jPassword = "new-secret"
+	next.ExcludeNamespaces = []string{"kube-system"}
+	next.EventWorkers = 8
+
+	changes := current.Diff(&next)
+
+	want := []Change{
+		{Setting: "neo4j_password", Old: "REDACTED", New: "REDACTED", Reloadable: true},
+		{Setting: "exclude_namespaces", Old: "[]", New: "[kube-system]", Reloadable: true},
+		{Setting: "event_workers", Old: "4", New: "8", Reloadable: false},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("expected changes %v, got %v", want, changes)
+	}
+
+	applied := current.Reloaded(&next)
+	if applied.Neo4jPassword != "new-secret" || applied.EventWorkers != current.EventWorkers {
+		t.Errorf("expected only reloadable settings to be applied, got password %q and %d workers",
+			applied.Neo4jPassword, applied.EventWorkers)
+	}
+}
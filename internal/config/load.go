@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration in layers: the YAML file named by the --config flag or the CONFIG_FILE
+// variable, then environment variables, then command-line flags, each overriding the previous one.
+// Settings set nowhere keep their defaults. The result is not validated; see Validate.
+func Load(args []string) (*Config, error) {
+	// The flags are parsed twice: first to find the file, then over the file and the environment.
+	var file string
+	first := defaults()
+	if err := newFlagSet(first, &file, os.Stderr).Parse(args); err != nil {
+		return nil, err
+	}
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg := defaults()
+	if file != "" {
+		if err := cfg.readFile(file); err != nil {
+			return nil, err
+		}
+	}
+	cfg.applyEnv()
+	if err := newFlagSet(cfg, &file, io.Discard).Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.args = args
+	return cfg, nil
+}
+
+// Reload reads the configuration again from the sources it was loaded from.
+func (c *Config) Reload() (*Config, error) {
+	return Load(c.args)
+}
+
+// readFile overrides the settings present in a YAML file. Unknown keys are an error, so that a
+// misspelt setting is not silently ignored.
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteYAML writes the configuration as YAML, in the format of the config file, with its secrets redacted.
+func (c *Config) WriteYAML(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("failed to encode configuration: %w", err)
+	}
+	return encoder.Close()
+}
+
+// newFlagSet defines the command-line flags over cfg, so that each flag given overrides its setting.
+// The password has no flag so that it does not show in the process list.
+func newFlagSet(cfg *Config, file *string, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("kube-kg", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(file, "config", *file, "YAML configuration file (CONFIG_FILE)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the configuration, with secrets redacted, and exit")
+
+	fs.StringVar(&cfg.ListenAddress, "listen-address", cfg.ListenAddress, "address the HTTP server listens on")
+	fs.StringVar(&cfg.KubeviewURL, "kubeview-url", cfg.KubeviewURL, "KubeView base URL")
+	fs.DurationVar(&cfg.KubeviewTimeout, "kubeview-timeout", cfg.KubeviewTimeout, "timeout of each KubeView request")
+	fs.StringVar(&cfg.Neo4jURI, "neo4j-uri", cfg.Neo4jURI, "Neo4j connection URI")
+	fs.StringVar(&cfg.Neo4jUser, "neo4j-user", cfg.Neo4jUser, "Neo4j user")
+	fs.IntVar(&cfg.Neo4jBatchSize, "neo4j-batch-size", cfg.Neo4jBatchSize, "rows written to Neo4j per query")
+	fs.IntVar(&cfg.Neo4jMaxPoolSize, "neo4j-max-connection-pool-size", cfg.Neo4jMaxPoolSize,
+		"maximum number of connections to Neo4j")
+	fs.StringVar(&cfg.ClientID, "client-id", cfg.ClientID, "client ID used to subscribe to KubeView")
+	fs.IntVar(&cfg.SyncFetchConcurrency, "sync-fetch-concurrency", cfg.SyncFetchConcurrency,
+		"namespaces fetched from KubeView at once during a sync")
+	fs.IntVar(&cfg.SyncWriteConcurrency, "sync-write-concurrency", cfg.SyncWriteConcurrency,
+		"namespaces written to Neo4j at once during a sync")
+	fs.DurationVar(&cfg.ResyncInterval, "resync-interval", cfg.ResyncInterval, "interval between periodic resyncs")
+	fs.StringVar(&cfg.ResyncMode, "resync-mode", cfg.ResyncMode, "periodic resync mode: full or incremental")
+	fs.Var((*listFlag)(&cfg.IncludeNamespaces), "include-namespaces",
+		"comma-separated namespace patterns kept in the graph")
+	fs.Var((*listFlag)(&cfg.ExcludeNamespaces), "exclude-namespaces",
+		"comma-separated namespace patterns left out of the graph")
+	fs.IntVar(&cfg.EventWorkers, "event-workers", cfg.EventWorkers, "workers applying SSE events")
+	fs.IntVar(&cfg.EventQueueSize, "event-queue-size", cfg.EventQueueSize, "capacity of each event worker's queue")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout,
+		"how long to wait for work in progress on shutdown")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "minimum level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log format: json or text")
+	return fs
+}
+
+// listFlag is a flag holding a comma-separated list.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
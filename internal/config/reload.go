@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the value of secrets wherever the configuration is shown.
+const redacted = "REDACTED"
+
+// Change describes a setting whose value differs between two configurations. Secrets are redacted.
+type Change struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	// Reloadable reports whether the change can be applied without restarting the service.
+	Reloadable bool `json:"reloadable"`
+}
+
+// Redacted returns a copy of the configuration whose secrets are replaced, for printing and logging.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	value := reflect.ValueOf(&copied).Elem()
+	for _, field := range settings() {
+		if field.secret && !value.Field(field.index).IsZero() {
+			value.Field(field.index).SetString(redacted)
+		}
+	}
+	return &copied
+}
+
+// Diff returns the settings that differ between c and next, in the order they are declared.
+func (c *Config) Diff(next *Config) []Change {
+	old, updated := reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem()
+	var changes []Change
+	for _, field := range settings() {
+		before, after := old.Field(field.index), updated.Field(field.index)
+		if reflect.DeepEqual(before.Interface(), after.Interface()) {
+			continue
+		}
+		changes = append(changes, Change{
+			Setting:    field.key,
+			Old:        field.format(before),
+			New:        field.format(after),
+			Reloadable: field.reload,
+		})
+	}
+	return changes
+}
+
+// Reloaded returns a copy of c with the reloadable settings of next, that is the configuration in effect
+// once a reload has applied next. Other settings keep their value until the service restarts.
+func (c *Config) Reloaded(next *Config) *Config {
+	copied := *c
+	value, updated := reflect.ValueOf(&copied).Elem(), reflect.ValueOf(next).Elem()
+	for _, field := range settings() {
+		if field.reload {
+			value.Field(field.index).Set(updated.Field(field.index))
+		}
+	}
+	return &copied
+}
+
+// setting describes a field of Config from its tags.
+type setting struct {
+	index  int
+	key    string
+	reload bool
+	secret bool
+}
+
+func (s setting) format(value reflect.Value) string {
+	switch {
+	case s.secret && !value.IsZero():
+		return redacted
+	case value.Kind() == reflect.Slice:
+		return fmt.Sprintf("[%s]", strings.Join(value.Interface().([]string), ", "))
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// settings lists the fields of Config that are read from the config file.
+func settings() []setting {
+	t := reflect.TypeOf(Config{})
+	var fields []setting
+	for i := range t.NumField() {
+		field := t.Field(i)
+		key := field.Tag.Get("yaml")
+		if !field.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		fields = append(fields, setting{
+			index:  i,
+			key:    key,
+			reload: field.Tag.Get("reload") == "true",
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+	return fields
+}
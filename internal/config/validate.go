@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the configuration, reporting every problem at once in a *ValidationError.
+func (c *Config) Validate() error {
+	problems := slices.Clone(c.envProblems)
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	if _, port, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		problems = append(problems, fmt.Sprintf("listen_address %q is not a host:port address", c.ListenAddress))
+	} else if _, err := strconv.Atoi(port); err != nil {
+		problems = append(problems, fmt.Sprintf("listen_address %q has an invalid port", c.ListenAddress))
+	}
+
+	if c.KubeviewURL == "" {
+		problems = append(problems, "kubeview_url is required")
+	} else if u, err := url.Parse(c.KubeviewURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.Host == "" {
+		problems = append(problems, fmt.Sprintf("kubeview_url %q is not an http or https URL", c.KubeviewURL))
+	}
+	neo4jSchemes := []string{"neo4j", "neo4j+s", "neo4j+ssc", "bolt", "bolt+s", "bolt+ssc"}
+	if c.Neo4jURI == "" {
+		problems = append(problems, "neo4j_uri is required")
+	} else if u, err := url.Parse(c.Neo4jURI); err != nil || !slices.Contains(neo4jSchemes, u.Scheme) || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("neo4j_uri %q must use one of the schemes %s",
+			c.Neo4jURI, strings.Join(neo4jSchemes, ", ")))
+	}
+	check(c.ClientID != "", "client_id is required")
+
+	for _, setting := range []struct {
+		key   string
+		value int
+	}{
+		{"neo4j_batch_size", c.Neo4jBatchSize},
+		{"neo4j_max_connection_pool_size", c.Neo4jMaxPoolSize},
+		{"kubeview_retry_max_attempts", c.KubeviewRetryMaxAttempts},
+		{"sync_namespace_max_attempts", c.SyncNamespaceMaxAttempts},
+		{"sync_fetch_concurrency", c.SyncFetchConcurrency},
+		{"sync_write_concurrency", c.SyncWriteConcurrency},
+		{"event_workers", c.EventWorkers},
+		{"event_queue_size", c.EventQueueSize},
+		{"event_replay_buffer_size", c.EventReplayBufferSize},
+	} {
+		check(setting.value > 0, "%s must be positive, got %d", setting.key, setting.value)
+	}
+	// A threshold of zero disables the circuit breaker.
+	check(c.KubeviewBreakerThreshold >= 0, "kubeview_breaker_threshold must not be negative, got %d",
+		c.KubeviewBreakerThreshold)
+
+	for _, setting := range []struct {
+		key        string
+		value      time.Duration
+		allowsZero bool
+	}{
+		{"kubeview_timeout", c.KubeviewTimeout, false},
+		{"kubeview_retry_initial_delay", c.KubeviewRetryInitialDelay, false},
+		{"kubeview_retry_max_delay", c.KubeviewRetryMaxDelay, false},
+		{"kubeview_breaker_cooldown", c.KubeviewBreakerCooldown, false},
+		{"readiness_cache_ttl", c.ReadinessCacheTTL, false},
+		{"liveness_stall_timeout", c.LivenessStallTimeout, false},
+		{"shutdown_timeout", c.ShutdownTimeout, false},
+		{"resync_interval", c.ResyncInterval, true},
+		{"event_coalesce_window", c.EventCoalesceWindow, true},
+	} {
+		if setting.allowsZero {
+			check(setting.value >= 0, "%s must not be negative, got %s", setting.key, setting.value)
+		} else {
+			check(setting.value > 0, "%s must be positive, got %s", setting.key, setting.value)
+		}
+	}
+	check(c.KubeviewRetryMaxDelay >= c.KubeviewRetryInitialDelay,
+		"kubeview_retry_max_delay %s is shorter than kubeview_retry_initial_delay %s",
+		c.KubeviewRetryMaxDelay, c.KubeviewRetryInitialDelay)
+	check(c.KubeviewRetryMultiplier >= 1, "kubeview_retry_multiplier must be at least 1, got %v",
+		c.KubeviewRetryMultiplier)
+	check(c.KubeviewRetryJitter >= 0 && c.KubeviewRetryJitter <= 1,
+		"kubeview_retry_jitter must be between 0 and 1, got %v", c.KubeviewRetryJitter)
+	check(c.TraceSamplerRatio >= 0 && c.TraceSamplerRatio <= 1,
+		"otel_traces_sampler_arg must be between 0 and 1, got %v", c.TraceSamplerRatio)
+
+	for _, setting := range []struct {
+		key     string
+		value   string
+		choices []string
+	}{
+		{"resync_mode", c.ResyncMode, []string{"full", "incremental"}},
+		{"log_level", c.LogLevel, []string{"debug", "info", "warn", "error"}},
+		{"log_format", c.LogFormat, []string{"json", "text"}},
+		{"otel_traces_exporter", c.TraceExporter, []string{"otlp-grpc", "otlp-http", "stdout", "none"}},
+		{"otel_metrics_exporter", c.MetricExporter, []string{"otlp-grpc", "otlp-http", "stdout", "none"}},
+		{"otel_traces_sampler", c.TraceSampler, []string{"always_on", "always_off", "traceidratio",
+			"parentbased_always_on", "parentbased_always_off", "parentbased_traceidratio"}},
+	} {
+		check(slices.Contains(setting.choices, setting.value), "%s must be one of %s, got %q",
+			setting.key, strings.Join(setting.choices, ", "), setting.value)
+	}
+
+	check((c.OtelExporterClientCertificate == "") == (c.OtelExporterClientKey == ""),
+		"otel_exporter_otlp_client_certificate and otel_exporter_otlp_client_key must be set together")
+
+	for _, pattern := range c.IncludeNamespaces {
+		_, err := path.Match(pattern, "")
+		check(err == nil, "include_namespaces pattern %q is malformed", pattern)
+	}
+	for _, pattern := range c.ExcludeNamespaces {
+		_, err := path.Match(pattern, "")
+		check(err == nil, "exclude_namespaces pattern %q is malformed", pattern)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package graph
+
+// relationshipKey identifies a relationship independently of its properties.
+type relationshipKey struct {
+	sourceID string
+	targetID string
+	relType  string
+}
+
+func keyOf(rel Relationship) relationshipKey {
+	return relationshipKey{sourceID: rel.SourceID, targetID: rel.TargetID, relType: rel.Type}
+}
+
+// DiffRelationships compares the relationships currently stored in the graph with the
+// desired set. It returns the stored relationships that are no longer desired and the
+// desired relationships that are not stored yet. Properties are ignored.
+func DiffRelationships(existing, desired []Relationship) (stale, missing []Relationship) {
+	want := make(map[relationshipKey]bool, len(desired))
+	for _, rel := range desired {
+		want[keyOf(rel)] = true
+	}
+	have := make(map[relationshipKey]bool, len(existing))
+	for _, rel := range existing {
+		have[keyOf(rel)] = true
+		if !want[keyOf(rel)] {
+			stale = append(stale, rel)
+		}
+	}
+	for _, rel := range desired {
+		if !have[keyOf(rel)] {
+			missing = append(missing, rel)
+			// Guard against duplicates in the desired set.
+			have[keyOf(rel)] = true
+		}
+	}
+	return stale, missing
+}
@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRelationships(t *testing.T) {
+	mounts := Relationship{SourceID: "pod", TargetID: "old-cm", Type: "MOUNTS"}
+	owns := Relationship{SourceID: "pod", TargetID: "rs", Type: "OWNS"}
+	newMounts := Relationship{SourceID: "pod", TargetID: "new-cm", Type: "MOUNTS"}
+
+	tests := []struct {
+		name        string
+		existing    []Relationship
+		desired     []Relationship
+		wantStale   []Relationship
+		wantMissing []Relationship
+	}{
+		{
+			name:     "nothing changed",
+			existing: []Relationship{owns, mounts},
+			desired:  []Relationship{owns, mounts},
+		},
+		{
+			name:        "volume switched to another config map",
+			existing:    []Relationship{owns, mounts},
+			desired:     []Relationship{owns, newMounts},
+			wantStale:   []Relationship{mounts},
+			wantMissing: []Relationship{newMounts},
+		},
+		{
+			name:      "all relationships removed",
+			existing:  []Relationship{owns, mounts},
+			wantStale: []Relationship{owns, mounts},
+		},
+		{
+			name:        "properties are ignored and duplicates collapsed",
+			existing:    []Relationship{owns},
+			desired:     []Relationship{{SourceID: "pod", TargetID: "rs", Type: "OWNS", Properties: map[string]interface{}{"a": 1}}, newMounts, newMounts},
+			wantMissing: []Relationship{newMounts},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stale, missing := DiffRelationships(tt.existing, tt.desired)
+
+			assert.Equal(t, tt.wantStale, stale)
+			assert.Equal(t, tt.wantMissing, missing)
+		})
+	}
+}
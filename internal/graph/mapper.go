@@ -3,6 +3,7 @@ package graph
 import (
 	"encoding/json"
 	"kube-kg/internal/kubeview"
+	"slices"
 )
 
 // Node represents a node in the graph.
@@ -14,18 +15,23 @@ type Node struct {
 
 // Relationship represents a relationship between two nodes in the graph.
 type Relationship struct {
-	SourceID string `json:"sourceId"`
-	TargetID string `json:"targetId"`
-	Type     string `json:"type"`
+	SourceID   string                 `json:"sourceId"`
+	TargetID   string                 `json:"targetId"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
 // KubernetesResourceToNode converts a Kubernetes resource to a graph node.
 func KubernetesResourceToNode(resource kubeview.KubernetesResource) Node {
 	properties := make(map[string]interface{})
 	properties["name"] = resource.Metadata.Name
+	properties["kind"] = resource.Kind
 	properties["namespace"] = resource.Metadata.Namespace
 	properties["creationTimestamp"] = resource.Metadata.CreationTimestamp
 	properties["uid"] = resource.Metadata.UID
+	if revision, ok := resource.Metadata.Revision(); ok {
+		properties["resourceVersion"] = revision
+	}
 
 	// Add all labels and annotations as properties
 	for k, v := range resource.Metadata.Labels {
@@ -42,45 +48,90 @@ func KubernetesResourceToNode(resource kubeview.KubernetesResource) Node {
 	}
 }
 
+// Lookup resolves the resources related to the one relationships are extracted for.
+// It is implemented by cache.Store.
+type Lookup interface {
+	// FindByName returns the resource of the given kind with the given name in a namespace.
+	FindByName(namespace, kind, name string) (kubeview.KubernetesResource, bool)
+	// FindByLabels returns the resources of a kind in a namespace whose labels match selector.
+	FindByLabels(namespace, kind string, selector map[string]string) []kubeview.KubernetesResource
+	// FindOwnedBy returns the resources that list the given uid in their owner references.
+	FindOwnedBy(uid string) []kubeview.KubernetesResource
+	// List returns the resources of a kind in a namespace.
+	List(namespace, kind string) []kubeview.KubernetesResource
+}
+
+// serviceSpec holds the part of a Service spec that relationships are derived from.
+type serviceSpec struct {
+	Selector map[string]string `json:"selector"`
+}
+
+// podSpec holds the part of a Pod spec that relationships are derived from.
+type podSpec struct {
+	Volumes []struct {
+		Name      string `json:"name"`
+		ConfigMap struct {
+			Name string `json:"name"`
+		} `json:"configMap"`
+		Secret struct {
+			SecretName string `json:"secretName"`
+		} `json:"secret"`
+	} `json:"volumes"`
+}
+
 // ExtractRelationships extracts relationships from a Kubernetes resource.
 func ExtractRelationships(resource kubeview.KubernetesResource, resources []kubeview.KubernetesResource) []Relationship {
+	return ExtractOutgoingRelationships(resource, sliceLookup(resources))
+}
+
+// ExtractOutgoingRelationships extracts the relationships that start at a resource,
+// resolving the related resources through lookup.
+func ExtractOutgoingRelationships(resource kubeview.KubernetesResource, lookup Lookup) []Relationship {
 	var relationships []Relationship
 
 	// Owner references
-	type OwnerReference struct {
-		UID string `json:"uid"`
-	}
-	for _, rawOwner := range resource.Metadata.OwnerReferences {
-		var owner OwnerReference
-		if err := json.Unmarshal(rawOwner, &owner); err == nil {
-			relationships = append(relationships, Relationship{
-				SourceID: resource.Metadata.UID,
-				TargetID: owner.UID,
-				Type:     "OWNS",
-			})
-		}
+	for _, ownerUID := range resource.Metadata.OwnerUIDs() {
+		relationships = append(relationships, Relationship{
+			SourceID: resource.Metadata.UID,
+			TargetID: ownerUID,
+			Type:     "OWNS",
+		})
 	}
 
 	// Service selectors
 	if resource.Kind == "Service" {
-		var serviceSpec struct {
-			Selector map[string]string `json:"selector"`
+		var spec serviceSpec
+		if err := json.Unmarshal(resource.Spec, &spec); err == nil {
+			for _, pod := range lookup.FindByLabels(resource.Metadata.Namespace, "Pod", spec.Selector) {
+				relationships = append(relationships, Relationship{
+					SourceID: resource.Metadata.UID,
+					TargetID: pod.Metadata.UID,
+					Type:     "SELECTS",
+				})
+			}
 		}
-		if err := json.Unmarshal(resource.Spec, &serviceSpec); err == nil {
-			for _, other := range resources {
-				if other.Kind == "Pod" {
-					match := true
-					for k, v := range serviceSpec.Selector {
-						if other.Metadata.Labels[k] != v {
-							match = false
-							break
-						}
+	}
+
+	// Pod volumes
+	if resource.Kind == "Pod" {
+		var spec podSpec
+		if err := json.Unmarshal(resource.Spec, &spec); err == nil {
+			for _, volume := range spec.Volumes {
+				if volume.ConfigMap.Name != "" {
+					if other, ok := lookup.FindByName(resource.Metadata.Namespace, "ConfigMap", volume.ConfigMap.Name); ok {
+						relationships = append(relationships, Relationship{
+							SourceID: resource.Metadata.UID,
+							TargetID: other.Metadata.UID,
+							Type:     "MOUNTS",
+						})
 					}
-					if match {
+				}
+				if volume.Secret.SecretName != "" {
+					if other, ok := lookup.FindByName(resource.Metadata.Namespace, "Secret", volume.Secret.SecretName); ok {
 						relationships = append(relationships, Relationship{
 							SourceID: resource.Metadata.UID,
 							TargetID: other.Metadata.UID,
-							Type:     "SELECTS",
+							Type:     "MOUNTS",
 						})
 					}
 				}
@@ -88,42 +139,56 @@ func ExtractRelationships(resource kubeview.KubernetesResource, resources []kube
 		}
 	}
 
-	// Pod volumes
-	if resource.Kind == "Pod" {
-		var podSpec struct {
-			Volumes []struct {
-				Name      string `json:"name"`
-				ConfigMap struct {
-					Name string `json:"name"`
-				} `json:"configMap"`
-				Secret struct {
-					SecretName string `json:"secretName"`
-				} `json:"secret"`
-			} `json:"volumes"`
+	return relationships
+}
+
+// ExtractIncomingRelationships extracts the relationships that end at a resource: the
+// Services selecting a Pod, the Pods mounting a ConfigMap or Secret, and the resources
+// owned by it. Together with ExtractOutgoingRelationships this lets a resource that
+// arrives after its neighbours be linked to them.
+func ExtractIncomingRelationships(resource kubeview.KubernetesResource, lookup Lookup) []Relationship {
+	var relationships []Relationship
+	uid := resource.Metadata.UID
+	namespace := resource.Metadata.Namespace
+
+	for _, child := range lookup.FindOwnedBy(uid) {
+		relationships = append(relationships, Relationship{
+			SourceID: child.Metadata.UID,
+			TargetID: uid,
+			Type:     "OWNS",
+		})
+	}
+
+	switch resource.Kind {
+	case "Pod":
+		for _, service := range lookup.List(namespace, "Service") {
+			var spec serviceSpec
+			if err := json.Unmarshal(service.Spec, &spec); err != nil || len(spec.Selector) == 0 {
+				continue
+			}
+			if labelsMatch(resource.Metadata.Labels, spec.Selector) {
+				relationships = append(relationships, Relationship{
+					SourceID: service.Metadata.UID,
+					TargetID: uid,
+					Type:     "SELECTS",
+				})
+			}
 		}
-		if err := json.Unmarshal(resource.Spec, &podSpec); err == nil {
-			for _, volume := range podSpec.Volumes {
-				if volume.ConfigMap.Name != "" {
-					for _, other := range resources {
-						if other.Kind == "ConfigMap" && other.Metadata.Name == volume.ConfigMap.Name {
-							relationships = append(relationships, Relationship{
-								SourceID: resource.Metadata.UID,
-								TargetID: other.Metadata.UID,
-								Type:     "MOUNTS",
-							})
-						}
-					}
-				}
-				if volume.Secret.SecretName != "" {
-					for _, other := range resources {
-						if other.Kind == "Secret" && other.Metadata.Name == volume.Secret.SecretName {
-							relationships = append(relationships, Relationship{
-								SourceID: resource.Metadata.UID,
-								TargetID: other.Metadata.UID,
-								Type:     "MOUNTS",
-							})
-						}
-					}
+	case "ConfigMap", "Secret":
+		for _, pod := range lookup.List(namespace, "Pod") {
+			var spec podSpec
+			if err := json.Unmarshal(pod.Spec, &spec); err != nil {
+				continue
+			}
+			for _, volume := range spec.Volumes {
+				if (resource.Kind == "ConfigMap" && volume.ConfigMap.Name == resource.Metadata.Name) ||
+					(resource.Kind == "Secret" && volume.Secret.SecretName == resource.Metadata.Name) {
+					relationships = append(relationships, Relationship{
+						SourceID: pod.Metadata.UID,
+						TargetID: uid,
+						Type:     "MOUNTS",
+					})
+					break
 				}
 			}
 		}
@@ -131,3 +196,57 @@ func ExtractRelationships(resource kubeview.KubernetesResource, resources []kube
 
 	return relationships
 }
+
+// sliceLookup implements Lookup with linear scans over a slice of resources.
+type sliceLookup []kubeview.KubernetesResource
+
+func (l sliceLookup) FindByName(namespace, kind, name string) (kubeview.KubernetesResource, bool) {
+	for _, other := range l {
+		if other.Metadata.Namespace == namespace && other.Kind == kind && other.Metadata.Name == name {
+			return other, true
+		}
+	}
+	return kubeview.KubernetesResource{}, false
+}
+
+func (l sliceLookup) FindByLabels(namespace, kind string, selector map[string]string) []kubeview.KubernetesResource {
+	if len(selector) == 0 {
+		return nil
+	}
+	var matches []kubeview.KubernetesResource
+	for _, other := range l {
+		if other.Metadata.Namespace == namespace && other.Kind == kind && labelsMatch(other.Metadata.Labels, selector) {
+			matches = append(matches, other)
+		}
+	}
+	return matches
+}
+
+func (l sliceLookup) FindOwnedBy(uid string) []kubeview.KubernetesResource {
+	var owned []kubeview.KubernetesResource
+	for _, other := range l {
+		if slices.Contains(other.Metadata.OwnerUIDs(), uid) {
+			owned = append(owned, other)
+		}
+	}
+	return owned
+}
+
+func (l sliceLookup) List(namespace, kind string) []kubeview.KubernetesResource {
+	var matches []kubeview.KubernetesResource
+	for _, other := range l {
+		if other.Metadata.Namespace == namespace && other.Kind == kind {
+			matches = append(matches, other)
+		}
+	}
+	return matches
+}
+
+func labelsMatch(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
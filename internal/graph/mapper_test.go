@@ -29,6 +29,8 @@ func TestKubernetesResourceToNode(t *testing.T) {
 	assert.Equal(t, "Pod", node.Label)
 	assert.Equal(t, "test-pod-uid", node.ID)
 	assert.Equal(t, "test-pod", node.Properties["name"])
+	assert.Equal(t, "Pod", node.Properties["kind"])
+	assert.Equal(t, int64(4711), node.Properties["resourceVersion"])
 }
 
 func TestExtractRelationships(t *testing.T) {
@@ -48,3 +50,37 @@ func TestExtractRelationships(t *testing.T) {
 	assert.Equal(t, service.Metadata.UID, relationships[0].SourceID)
 	assert.Equal(t, pod.Metadata.UID, relationships[0].TargetID)
 }
+
+func TestExtractIncomingRelationships(t *testing.T) {
+	pod := loadTestResource(t, "testdata/pod.json")
+	replicaSet := loadTestResource(t, "testdata/replicaset.json")
+	service := loadTestResource(t, "testdata/service.json")
+	configMap := loadTestResource(t, "testdata/configmap.json")
+
+	lookup := sliceLookup{pod, replicaSet, service, configMap}
+
+	// A pod that arrives after its service is still selected by it.
+	relationships := ExtractIncomingRelationships(pod, lookup)
+	assert.Equal(t, []Relationship{{SourceID: service.Metadata.UID, TargetID: pod.Metadata.UID, Type: "SELECTS"}}, relationships)
+
+	// A config map that arrives after the pod mounting it is linked to it.
+	relationships = ExtractIncomingRelationships(configMap, lookup)
+	assert.Equal(t, []Relationship{{SourceID: pod.Metadata.UID, TargetID: configMap.Metadata.UID, Type: "MOUNTS"}}, relationships)
+
+	// An owner that arrives after its children is linked to them.
+	relationships = ExtractIncomingRelationships(replicaSet, lookup)
+	assert.Equal(t, []Relationship{{SourceID: pod.Metadata.UID, TargetID: replicaSet.Metadata.UID, Type: "OWNS"}}, relationships)
+}
+
+func TestExtractOutgoingRelationships_SelectorScope(t *testing.T) {
+	pod := loadTestResource(t, "testdata/pod.json")
+	service := loadTestResource(t, "testdata/service.json")
+
+	otherNamespace := service
+	otherNamespace.Metadata.Namespace = "other"
+	assert.Empty(t, ExtractOutgoingRelationships(otherNamespace, sliceLookup{pod}))
+
+	noSelector := service
+	noSelector.Spec = json.RawMessage(`{}`)
+	assert.Empty(t, ExtractOutgoingRelationships(noSelector, sliceLookup{pod}))
+}
@@ -3,15 +3,22 @@ package kubeview
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/r3labs/sse/v2"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -35,6 +42,10 @@ type Event struct {
 	Type string `json:"type"`
 
 	Object KubernetesResource `json:"object"`
+
+	// SpanContext identifies the span in which the event was received, so that the spans
+	// processing it can link to it. It is not valid for events that were not streamed.
+	SpanContext trace.SpanContext `json:"-"`
 }
 
 // KubernetesResource represents a generic Kubernetes resource.
@@ -60,49 +71,120 @@ type ObjectMeta struct {
 	ManagedFields     []json.RawMessage `json:"managedFields"`
 }
 
+// OwnerUIDs returns the uids listed in the object's owner references.
+func (m ObjectMeta) OwnerUIDs() []string {
+	var uids []string
+	for _, rawOwner := range m.OwnerReferences {
+		var owner struct {
+			UID string `json:"uid"`
+		}
+		if err := json.Unmarshal(rawOwner, &owner); err == nil && owner.UID != "" {
+			uids = append(uids, owner.UID)
+		}
+	}
+	return uids
+}
+
+// Revision returns the resource version as a number, for ordering versions of the same object.
+// Kubernetes treats resource versions as opaque, but the API server fills them from etcd
+// revisions, which only increase. It reports false when the version is missing or not numeric.
+func (m ObjectMeta) Revision() (int64, bool) {
+	revision, err := strconv.ParseInt(m.ResourceVersion, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return revision, true
+}
+
 // Client is a client for the KubeView API.
 type Client struct {
 	httpClient *http.Client
-	baseURL    string
-	tracer     trace.Tracer
+	// streamClient has no overall timeout so that the long-lived SSE connection is not cut off.
+	streamClient   *http.Client
+	endpoint       endpoint
+	tracer         trace.Tracer
+	retryPolicy    RetryPolicy
+	breaker        *CircuitBreaker
+	rejectedEvents metric.Int64Counter
+	// requestDuration records the latency of each request attempt.
+	requestDuration metric.Float64Histogram
+	position        streamPosition
+	stream          streamState
+	gaps            chan StreamGap
 }
 
-// NewClient creates a new KubeView API client.
-func NewClient(baseURL string) *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		baseURL: baseURL,
-		tracer:  otel.Tracer("kube-kg/internal/kubeview"),
+// Option configures optional behaviour of a Client.
+type Option func(*Client)
+
+// WithRetryPolicy sets the policy used to retry failed requests and SSE reconnections.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
 	}
 }
 
-// ListNamespaces fetches the list of namespaces from the KubeView API.
-func (c *Client) ListNamespaces(ctx context.Context) (*NamespaceListResult, error) {
-	ctx, _ = c.tracer.Start(ctx, "ListNamespaces")
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/namespaces", c.baseURL), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// WithTimeout sets how long each request to KubeView may take. It does not apply to the SSE stream.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
 	}
+}
 
-	resp, err := c.httpClient.Do(req)
+// WithCircuitBreaker sets the circuit breaker guarding requests to KubeView.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = breaker
+	}
+}
+
+// NewClient creates a new KubeView API client.
+func NewClient(baseURL string, opts ...Option) *Client {
+	meter := otel.Meter("kube-kg/internal/kubeview")
+	rejectedEvents, err := meter.Int64Counter("kubeview.sse.events.rejected",
+		metric.WithDescription("SSE messages that could not be decoded into a KubeView event"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform request: %w", err)
+		slog.Warn("failed to create metric", "name", "kubeview.sse.events.rejected", "err", err)
+	}
+	requestDuration, err := meter.Float64Histogram("kubeview.request.duration",
+		metric.WithDescription("Time taken by KubeView API requests, by endpoint and status"),
+		metric.WithUnit("s"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "kubeview.request.duration", "err", err)
 	}
-	defer func(body io.ReadCloser) {
-		if err := body.Close(); err != nil {
-			slog.Error("failed to close response body", "err", err)
-		}
-	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	c := &Client{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		streamClient:    &http.Client{},
+		endpoint:        endpoint{baseURL: baseURL},
+		tracer:          otel.Tracer("kube-kg/internal/kubeview"),
+		retryPolicy:     DefaultRetryPolicy(),
+		breaker:         NewCircuitBreaker(5, 30*time.Second),
+		rejectedEvents:  rejectedEvents,
+		requestDuration: requestDuration,
+		gaps:            make(chan StreamGap, 1),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CircuitState returns the state of the circuit breaker guarding requests to KubeView.
+func (c *Client) CircuitState() BreakerState {
+	return c.breaker.State()
+}
+
+// ListNamespaces fetches the list of namespaces from the KubeView API.
+func (c *Client) ListNamespaces(ctx context.Context) (*NamespaceListResult, error) {
+	ctx, span := c.tracer.Start(ctx, "ListNamespaces")
+	defer span.End()
 
 	var result NamespaceListResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	baseURL, _ := c.endpoint.get()
+	if err := c.getJSON(ctx, "namespaces", fmt.Sprintf("%s/api/namespaces", baseURL), &result); err != nil {
+		return nil, err
 	}
 
 	return &result, nil
@@ -116,76 +198,221 @@ func (c *Client) FetchNamespaceResources(ctx context.Context, namespace string,
 	))
 	defer span.End()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/fetch/%s?clientID=%s", c.baseURL, namespace, clientID), nil)
+	var result NamespaceResources
+	baseURL, _ := c.endpoint.get()
+	url := fmt.Sprintf("%s/api/fetch/%s?clientID=%s", baseURL, namespace, clientID)
+	if err := c.getJSON(ctx, "fetch", url, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// getJSON performs a GET request, retrying per the client's retry policy, and decodes the JSON response into out.
+// Each attempt runs in a span of its own, which is propagated to KubeView in the traceparent header, and its
+// latency is recorded under endpoint, which names the API being called.
+func (c *Client) getJSON(ctx context.Context, endpoint, url string, out interface{}) error {
+	return c.withRetry(ctx, func(ctx context.Context) error {
+		return c.getJSONOnce(ctx, endpoint, url, out)
+	})
+}
+
+// getJSONOnce makes a single attempt of getJSON, outside the retry policy and the circuit breaker.
+func (c *Client) getJSONOnce(ctx context.Context, endpoint, url string, out interface{}) (err error) {
+	ctx, span := c.tracer.Start(ctx, "HTTP GET",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(http.MethodGet),
+			semconv.HTTPURL(url),
+			attribute.String("kubeview.endpoint", endpoint),
+		))
+	defer func() { endSpan(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	c.requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.String("status", status),
+	))
 	if err != nil {
-		return nil, fmt.Errorf("failed to perform request: %w", err)
+		return fmt.Errorf("failed to perform request: %w", err)
 	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	body := &countingReader{reader: resp.Body}
+	defer func() { span.SetAttributes(semconv.HTTPResponseContentLength(int(body.count))) }()
 	defer func(body io.ReadCloser) {
 		if err := body.Close(); err != nil {
-			slog.Error("failed to close response body", "err", err)
+			slog.ErrorContext(ctx, "failed to close response body", "err", err)
 		}
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	var result NamespaceResources
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
-
-	return result, nil
+	return nil
 }
 
 // StreamUpdates connects to the KubeView SSE stream and sends events to the provided channel.
+// Lost connections are re-established following the client's retry policy, without
+// an attempt limit, until ctx is cancelled. The stream subscribes as clientID until SetEndpoint changes it.
 func (c *Client) StreamUpdates(ctx context.Context, clientID string, eventChan chan<- Event) {
+	c.endpoint.mu.Lock()
+	c.endpoint.clientID = clientID
+	c.endpoint.mu.Unlock()
+
 	go func() {
 		defer close(eventChan)
+		attempt := 1
 		for {
-			select {
-			case <-ctx.Done():
-				slog.Info("stopping SSE client")
-				return
-			default:
-				err := c.connectAndStream(ctx, clientID, eventChan)
+			if err := c.breaker.Allow(); err != nil {
+				slog.WarnContext(ctx, "not connecting to sse stream", "err", err)
+			} else {
+				connected, err := c.connectAndStream(ctx, eventChan)
+				if ctx.Err() != nil {
+					slog.InfoContext(ctx, "stopping SSE client")
+					return
+				}
+				if errors.Is(err, errEndpointChanged) {
+					slog.InfoContext(ctx, "reconnecting to sse stream at new endpoint")
+					attempt = 1
+					continue
+				}
+				if connected {
+					c.breaker.RecordSuccess()
+					attempt = 1
+				} else {
+					c.breaker.RecordFailure()
+				}
 				if err != nil {
-					slog.Error("sse connection error", "err", err)
-					// Implement backoff before retrying
-					time.Sleep(5 * time.Second)
+					slog.ErrorContext(ctx, "sse connection error", "err", err, "attempt", attempt)
+				} else {
+					slog.WarnContext(ctx, "sse stream closed by server", "attempt", attempt)
 				}
 			}
+
+			if err := c.retryPolicy.Wait(ctx, attempt); err != nil {
+				slog.InfoContext(ctx, "stopping SSE client")
+				return
+			}
+			attempt++
 		}
 	}()
 }
 
-func (c *Client) connectAndStream(ctx context.Context, clientID string, eventChan chan<- Event) error {
-	url := fmt.Sprintf("%s/updates?clientID=%s", c.baseURL, clientID)
-	slog.Info("connecting to SSE stream", "url", url)
+// connectAndStream subscribes to the SSE stream until it ends, reporting whether a connection was established.
+// When reconnecting it resumes from the last event ID and signals a gap unless the
+// first event received proves that nothing was missed. It returns errEndpointChanged if the endpoint
+// changed meanwhile.
+func (c *Client) connectAndStream(ctx context.Context, eventChan chan<- Event) (bool, error) {
+	ctx, disconnect := c.endpoint.connect(ctx)
+	defer disconnect(nil)
+	baseURL, clientID := c.endpoint.get()
+	url := fmt.Sprintf("%s/updates?clientID=%s", baseURL, clientID)
+	resumeFrom, reconnecting := c.position.get()
+	slog.InfoContext(ctx, "connecting to SSE stream", "url", url, "lastEventID", resumeFrom)
 
 	client := sse.NewClient(url)
-	client.Connection = c.httpClient
+	client.Connection = c.streamClient
+	// Reconnection is handled by StreamUpdates so that it follows the retry policy and circuit breaker.
+	client.ReconnectStrategy = &backoff.StopBackOff{}
+	if resumeFrom != "" {
+		// Sent as the Last-Event-ID header so that the server can replay missed events.
+		client.LastEventID.Store([]byte(resumeFrom))
+	}
+
+	// The connection span ends once the stream is established rather than lasting as long as the stream.
+	connectCtx, connectSpan := c.tracer.Start(ctx, "HTTP GET",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(http.MethodGet),
+			semconv.HTTPURL(url),
+			attribute.String("kubeview.endpoint", "updates"),
+		))
+	otel.GetTextMapPropagator().Inject(connectCtx, propagation.MapCarrier(client.Headers))
+
+	// The first connection needs no proof of continuity: the initial sync covers it.
+	verified := !reconnecting
+	var connected bool
+	client.OnConnect(func(*sse.Client) {
+		connected = true
+		connectSpan.SetAttributes(semconv.HTTPStatusCode(http.StatusOK))
+		connectSpan.End()
+		c.position.markConnected()
+		c.stream.markConnected()
+		if !verified && resumeFrom == "" {
+			verified = true
+			c.signalGap("", "no event id to resume from")
+		}
+	})
+
+	defer c.stream.markDisconnected()
+	err := client.SubscribeWithContext(ctx, "", func(msg *sse.Event) {
+		if string(msg.Event) == eventTypePing {
+			c.stream.markPing()
+			slog.DebugContext(ctx, "received ping")
+			return
+		}
 
-	return client.SubscribeWithContext(ctx, "", func(msg *sse.Event) {
 		if len(msg.Data) == 0 {
 			return // Ignore empty messages
 		}
+		c.stream.markEvent()
 
-		if string(msg.Event) == "ping" {
-			slog.Debug("received ping")
-			return
+		// Messages without an ID inherit the last one seen, which never proves continuity.
+		id := string(msg.ID)
+		ctx, span := c.startEventSpan(ctx, msg)
+		defer span.End()
+		if !verified {
+			verified = true
+			if !continues(resumeFrom, id) {
+				slog.WarnContext(ctx, "sse stream resumed without continuity", "lastEventID", resumeFrom, "eventID", id)
+				c.signalGap(resumeFrom, "server did not resume from last event id")
+			}
+		}
+		if id != "" {
+			c.position.advance(id)
 		}
 
-		var event Event
-		if err := json.Unmarshal(msg.Data, &event); err != nil {
-			slog.Error("failed to unmarshal sse event", "err", err, "data", string(msg.Data))
+		event, err := DecodeEvent(msg)
+		if err != nil {
+			reason := "unknown"
+			var invalid *InvalidEventError
+			if errors.As(err, &invalid) {
+				reason = invalid.Reason
+			}
+			c.rejectedEvents.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+			slog.ErrorContext(ctx, "rejected sse event", "err", err, "event", string(msg.Event))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			return
 		}
-		eventChan <- event
+		span.SetAttributes(eventAttributes(event)...)
+		event.SpanContext = span.SpanContext()
+
+		select {
+		case eventChan <- event:
+		case <-ctx.Done():
+		}
 	})
+	if errors.Is(context.Cause(ctx), errEndpointChanged) {
+		err = errEndpointChanged
+	}
+	if !connected {
+		endSpan(connectSpan, err)
+	}
+	return connected, err
 }
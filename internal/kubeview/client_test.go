@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -73,6 +74,17 @@ func TestClient_FetchNamespaceResources(t *testing.T) {
 	assert.Equal(t, "test-pod", pods[0].Metadata.Name)
 }
 
+func TestObjectMeta_Revision(t *testing.T) {
+	revision, ok := ObjectMeta{ResourceVersion: "12345"}.Revision()
+	assert.True(t, ok)
+	assert.Equal(t, int64(12345), revision)
+
+	_, ok = ObjectMeta{ResourceVersion: ""}.Revision()
+	assert.False(t, ok)
+	_, ok = ObjectMeta{ResourceVersion: "v1"}.Revision()
+	assert.False(t, ok)
+}
+
 func TestClient_StreamUpdates(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "/updates", r.URL.Path)
@@ -92,8 +104,10 @@ func TestClient_StreamUpdates(t *testing.T) {
 		_, _ = fmt.Fprintf(w, "event: ping\n\n")
 		flusher.Flush()
 
-		// Send a real event
-		_, _ = fmt.Fprintf(w, "event: message\ndata: {\"type\":\"ADDED\",\"object\":{\"kind\":\"Pod\",\"metadata\":{\"name\":\"test-pod\"}}}\n\n")
+		// Send an event that is rejected, followed by events in the spec and wrapped shapes
+		_, _ = fmt.Fprintf(w, "event: bogus\ndata: {}\n\n")
+		_, _ = fmt.Fprintf(w, "event: add\ndata: {\"kind\":\"Pod\",\"metadata\":{\"name\":\"test-pod\",\"uid\":\"test-pod-uid\"}}\n\n")
+		_, _ = fmt.Fprintf(w, "event: message\ndata: {\"type\":\"ADDED\",\"object\":{\"kind\":\"Pod\",\"metadata\":{\"name\":\"test-pod\",\"uid\":\"test-pod-uid\"}}}\n\n")
 		flusher.Flush()
 	}))
 	defer server.Close()
@@ -105,12 +119,49 @@ func TestClient_StreamUpdates(t *testing.T) {
 
 	client.StreamUpdates(ctx, "test-client", eventChan)
 
-	select {
-	case event := <-eventChan:
-		assert.Equal(t, "ADDED", event.Type)
-		assert.Equal(t, "Pod", event.Object.Kind)
-		assert.Equal(t, "test-pod", event.Object.Metadata.Name)
-	case <-time.After(5 * time.Second):
-		t.Fatal("timed out waiting for event")
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-eventChan:
+			assert.Equal(t, EventTypeAdd, event.Type)
+			assert.Equal(t, "Pod", event.Object.Kind)
+			assert.Equal(t, "test-pod", event.Object.Metadata.Name)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
 	}
+
+	status := client.StreamStatus()
+	assert.NotNil(t, status.LastPingAt)
+	assert.NotNil(t, status.LastEventAt)
+}
+
+func TestClient_ServerStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/status", r.URL.Path)
+		_, _ = w.Write([]byte(`{"version":"2.1.0","buildInfo":"abc123","clusterHost":"https://k8s:6443","mode":"in-cluster"}`))
+	}))
+	defer server.Close()
+
+	status, err := NewClient(server.URL).ServerStatus(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, &ServerStatus{Version: "2.1.0", BuildInfo: "abc123", ClusterHost: "https://k8s:6443", Mode: "in-cluster"},
+		status)
+}
+
+func TestClient_ServerStatusIsNotRetried(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	breaker := NewCircuitBreaker(1, time.Minute)
+	client := NewClient(server.URL, WithCircuitBreaker(breaker))
+
+	_, err := client.ServerStatus(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), requests.Load())
+	assert.Equal(t, BreakerClosed, breaker.State(), "status checks do not open the circuit breaker")
 }
@@ -0,0 +1,59 @@
+package kubeview
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// errEndpointChanged ends the SSE connection when the client switches to another endpoint.
+var errEndpointChanged = errors.New("kubeview endpoint changed")
+
+// endpoint holds where the client connects to, which can change while it runs.
+type endpoint struct {
+	mu       sync.Mutex
+	baseURL  string
+	clientID string
+	// disconnect ends the current SSE connection, if any.
+	disconnect context.CancelCauseFunc
+}
+
+func (e *endpoint) get() (baseURL, clientID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.baseURL, e.clientID
+}
+
+// connect returns a context for an SSE connection, cancelled when the endpoint changes.
+func (e *endpoint) connect(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.disconnect = cancel
+	return ctx, cancel
+}
+
+// SetEndpoint switches the client to another KubeView base URL and SSE client ID. Requests started
+// afterwards use the new endpoint, and the SSE stream reconnects to it at once. As events from another
+// server cannot be resumed from, changing the URL is reported as a stream gap.
+func (c *Client) SetEndpoint(ctx context.Context, baseURL, clientID string) {
+	c.endpoint.mu.Lock()
+	urlChanged := baseURL != c.endpoint.baseURL
+	changed := urlChanged || clientID != c.endpoint.clientID
+	c.endpoint.baseURL = baseURL
+	c.endpoint.clientID = clientID
+	disconnect := c.endpoint.disconnect
+	c.endpoint.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	slog.InfoContext(ctx, "switching kubeview endpoint", "url", baseURL, "clientID", clientID)
+	if urlChanged {
+		c.position.reset()
+	}
+	if disconnect != nil {
+		disconnect(errEndpointChanged)
+	}
+}
@@ -0,0 +1,76 @@
+package kubeview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// holdingServer lists a single namespace, and sends one event per SSE connection and keeps the stream
+// open, reporting the client ID and Last-Event-ID of each connection.
+func holdingServer(t *testing.T, namespace, eventID string) (*httptest.Server, chan [2]string) {
+	t.Helper()
+
+	connections := make(chan [2]string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/namespaces" {
+			_, _ = fmt.Fprintf(w, `{"namespaces": [%q]}`, namespace)
+			return
+		}
+		connections <- [2]string{r.URL.Query().Get("clientID"), r.Header.Get("Last-Event-ID")}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, podFrame(eventID))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server, connections
+}
+
+func nextConnection(t *testing.T, connections chan [2]string) [2]string {
+	t.Helper()
+	select {
+	case connection := <-connections:
+		return connection
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connection")
+		return [2]string{}
+	}
+}
+
+func TestClient_SetEndpoint(t *testing.T) {
+	first, firstConnections := holdingServer(t, "first", "41")
+	second, secondConnections := holdingServer(t, "second", "1")
+	client, eventChan := streamForTest(t, first.URL)
+
+	assert.Equal(t, [2]string{"test-client", ""}, nextConnection(t, firstConnections))
+	select {
+	case <-eventChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	client.SetEndpoint(context.Background(), first.URL, "renamed-client")
+	assert.Equal(t, [2]string{"renamed-client", "41"}, nextConnection(t, firstConnections),
+		"changing the client ID alone should resume the stream")
+
+	client.SetEndpoint(context.Background(), second.URL, "renamed-client")
+	assert.Equal(t, [2]string{"renamed-client", ""}, nextConnection(t, secondConnections),
+		"a new server should not be asked to resume")
+	select {
+	case gap := <-client.Gaps():
+		assert.Equal(t, "no event id to resume from", gap.Reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for gap")
+	}
+
+	result, err := client.ListNamespaces(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"second"}, result.Namespaces)
+}
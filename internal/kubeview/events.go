@@ -0,0 +1,97 @@
+package kubeview
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/r3labs/sse/v2"
+)
+
+// Event types as carried in the SSE event field.
+const (
+	EventTypeAdd    = "add"
+	EventTypeUpdate = "update"
+	EventTypeDelete = "delete"
+	eventTypePing   = "ping"
+)
+
+// InvalidEventError is returned by DecodeEvent for SSE messages that are not valid KubeView events.
+type InvalidEventError struct {
+	// Event is the SSE event name of the rejected message.
+	Event string
+	// Reason is a short, low-cardinality description of why the message was rejected.
+	Reason string
+	// Err is the underlying decoding error, if any.
+	Err error
+}
+
+func (e *InvalidEventError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid sse event %q: %s: %v", e.Event, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("invalid sse event %q: %s", e.Event, e.Reason)
+}
+
+func (e *InvalidEventError) Unwrap() error {
+	return e.Err
+}
+
+// wrappedEvent is the legacy {type, object} payload sent in the data field of an unnamed SSE event.
+type wrappedEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// DecodeEvent builds an Event from an SSE message. Per the KubeView API spec the event
+// name is the event type (add, update or delete) and the data is the bare Kubernetes
+// resource. For compatibility, unnamed messages whose data is a wrapped {type, object}
+// payload are accepted too; Kubernetes watch types (ADDED, MODIFIED, DELETED) are
+// normalised to their KubeView equivalents. Anything else is rejected with an
+// *InvalidEventError.
+func DecodeEvent(msg *sse.Event) (Event, error) {
+	name := string(msg.Event)
+	switch name {
+	case EventTypeAdd, EventTypeUpdate, EventTypeDelete:
+		return decodeResource(name, name, msg.Data)
+	case "", "message":
+		var wrapped wrappedEvent
+		if err := json.Unmarshal(msg.Data, &wrapped); err != nil {
+			return Event{}, &InvalidEventError{Event: name, Reason: "malformed payload", Err: err}
+		}
+		eventType, ok := normalizeEventType(wrapped.Type)
+		if !ok {
+			return Event{}, &InvalidEventError{Event: name, Reason: "unknown event type",
+				Err: fmt.Errorf("type %q", wrapped.Type)}
+		}
+		if len(wrapped.Object) == 0 {
+			return Event{}, &InvalidEventError{Event: name, Reason: "missing object"}
+		}
+		return decodeResource(name, eventType, wrapped.Object)
+	default:
+		return Event{}, &InvalidEventError{Event: name, Reason: "unknown event name"}
+	}
+}
+
+func decodeResource(name, eventType string, data []byte) (Event, error) {
+	var resource KubernetesResource
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return Event{}, &InvalidEventError{Event: name, Reason: "malformed resource", Err: err}
+	}
+	if resource.Metadata.UID == "" {
+		return Event{}, &InvalidEventError{Event: name, Reason: "resource has no uid"}
+	}
+	return Event{Type: eventType, Object: resource}, nil
+}
+
+func normalizeEventType(eventType string) (string, bool) {
+	switch eventType {
+	case EventTypeAdd, "ADDED":
+		return EventTypeAdd, true
+	case EventTypeUpdate, "MODIFIED":
+		return EventTypeUpdate, true
+	case EventTypeDelete, "DELETED":
+		return EventTypeDelete, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,75 @@
+package kubeview
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/r3labs/sse/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadFrame reads a recorded SSE frame and parses its fields the way the SSE client does.
+func loadFrame(t *testing.T, name string) *sse.Event {
+	t.Helper()
+
+	raw, err := os.ReadFile(filepath.Join("testdata", "sse", name))
+	require.NoError(t, err)
+
+	msg := &sse.Event{}
+	var data [][]byte
+	for _, line := range bytes.Split(bytes.TrimRight(raw, "\n"), []byte("\n")) {
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "id":
+			msg.ID = value
+		case "event":
+			msg.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	msg.Data = bytes.Join(data, []byte("\n"))
+	return msg
+}
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		frame      string
+		wantType   string
+		wantKind   string
+		wantUID    string
+		wantReason string
+	}{
+		{frame: "add.txt", wantType: EventTypeAdd, wantKind: "Pod", wantUID: "6f1c2f1e-0b1a-4a51-9d36-1f0a2b3c4d5e"},
+		{frame: "update.txt", wantType: EventTypeUpdate, wantKind: "Service", wantUID: "0a9b8c7d-6e5f-4a3b-2c1d-0e9f8a7b6c5d"},
+		{frame: "delete.txt", wantType: EventTypeDelete, wantKind: "ConfigMap", wantUID: "3c2b1a09-8f7e-4d6c-5b4a-392817160504"},
+		{frame: "wrapped_watch_type.txt", wantType: EventTypeAdd, wantKind: "Pod", wantUID: "legacy-pod-uid"},
+		{frame: "wrapped_message.txt", wantType: EventTypeDelete, wantKind: "Pod", wantUID: "legacy-pod-uid"},
+		{frame: "unknown_event.txt", wantReason: "unknown event name"},
+		{frame: "malformed.txt", wantReason: "malformed resource"},
+		{frame: "missing_uid.txt", wantReason: "resource has no uid"},
+		{frame: "wrapped_unknown_type.txt", wantReason: "unknown event type"},
+		{frame: "unnamed_bare_resource.txt", wantReason: "unknown event type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.frame, func(t *testing.T) {
+			event, err := DecodeEvent(loadFrame(t, tt.frame))
+
+			if tt.wantReason != "" {
+				var invalid *InvalidEventError
+				require.ErrorAs(t, err, &invalid)
+				assert.Equal(t, tt.wantReason, invalid.Reason)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, event.Type)
+			assert.Equal(t, tt.wantKind, event.Object.Kind)
+			assert.Equal(t, tt.wantUID, event.Object.Metadata.UID)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package kubeview
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StreamGap signals that events may have been missed while the SSE stream was
+// disconnected, so the state derived from it can no longer be trusted.
+type StreamGap struct {
+	// LastEventID is the ID of the last event received before the disconnect, if any.
+	LastEventID string
+	// Reason explains why continuity could not be proven.
+	Reason string
+	// DetectedAt is when the gap was detected.
+	DetectedAt time.Time
+}
+
+// streamPosition tracks the SSE event ID the stream can be resumed from.
+type streamPosition struct {
+	mu          sync.Mutex
+	lastEventID string
+	connected   bool
+}
+
+func (p *streamPosition) get() (lastEventID string, connectedBefore bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastEventID, p.connected
+}
+
+func (p *streamPosition) markConnected() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connected = true
+}
+
+func (p *streamPosition) advance(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastEventID = id
+}
+
+// reset forgets the last event ID, so that the stream reconnects without resuming and reports a gap.
+func (p *streamPosition) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastEventID = ""
+}
+
+// continues reports whether next is provably the event following prev. Only
+// sequential numeric IDs can prove that; anything else counts as a gap.
+func continues(prev, next string) bool {
+	prevID, err := strconv.ParseUint(prev, 10, 64)
+	if err != nil {
+		return false
+	}
+	nextID, err := strconv.ParseUint(next, 10, 64)
+	if err != nil {
+		return false
+	}
+	return nextID == prevID+1
+}
+
+// Gaps returns the channel on which detected stream gaps are reported. Gaps that
+// occur while an earlier one is still pending are coalesced into it.
+func (c *Client) Gaps() <-chan StreamGap {
+	return c.gaps
+}
+
+func (c *Client) signalGap(lastEventID, reason string) {
+	gap := StreamGap{LastEventID: lastEventID, Reason: reason, DetectedAt: time.Now()}
+	select {
+	case c.gaps <- gap:
+	default:
+		// A resync is already pending and will cover this gap too.
+	}
+}
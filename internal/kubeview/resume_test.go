@@ -0,0 +1,133 @@
+package kubeview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContinues(t *testing.T) {
+	assert.True(t, continues("41", "42"))
+	assert.False(t, continues("41", "43"))
+	assert.False(t, continues("41", "41"))
+	assert.False(t, continues("", "1"))
+	assert.False(t, continues("abc", "abd"))
+}
+
+// resumingServer serves one SSE frame per connection, chosen by the connection number,
+// and records the Last-Event-ID header sent with each connection.
+func resumingServer(t *testing.T, frames ...string) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var lastEventIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connection := len(lastEventIDs)
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if connection < len(frames) {
+			_, _ = fmt.Fprint(w, frames[connection])
+			w.(http.Flusher).Flush()
+			return
+		}
+		// Keep later connections open until the client goes away.
+		<-r.Context().Done()
+	}))
+	// Registered before the client's cancel so that it runs after it and open streams can end.
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), lastEventIDs...)
+	}
+}
+
+func podFrame(id string) string {
+	frame := "event: add\ndata: {\"kind\":\"Pod\",\"metadata\":{\"name\":\"p\",\"uid\":\"p-uid\"}}\n\n"
+	if id != "" {
+		frame = "id: " + id + "\n" + frame
+	}
+	return frame
+}
+
+func streamForTest(t *testing.T, serverURL string) (*Client, chan Event) {
+	t.Helper()
+
+	client := NewClient(serverURL, WithRetryPolicy(fastRetryPolicy()))
+	eventChan := make(chan Event, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client.StreamUpdates(ctx, "test-client", eventChan)
+	return client, eventChan
+}
+
+func TestStreamUpdates_ResumesFromLastEventID(t *testing.T) {
+	server, lastEventIDs := resumingServer(t, podFrame("1")+podFrame("2"), podFrame("3"))
+	client, eventChan := streamForTest(t, server.URL)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-eventChan:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+
+	ids := lastEventIDs()
+	require.GreaterOrEqual(t, len(ids), 2)
+	assert.Equal(t, "", ids[0])
+	assert.Equal(t, "2", ids[1])
+	select {
+	case gap := <-client.Gaps():
+		t.Fatalf("unexpected gap: %+v", gap)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	status := client.StreamStatus()
+	assert.GreaterOrEqual(t, status.Reconnects, int64(1))
+	assert.Equal(t, "3", status.LastEventID)
+}
+
+func TestStreamUpdates_SignalsGap(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []string
+		want   string
+	}{
+		{
+			name:   "server does not honour Last-Event-ID",
+			frames: []string{podFrame("1"), podFrame("7")},
+			want:   "server did not resume from last event id",
+		},
+		{
+			name:   "server sends no event IDs",
+			frames: []string{podFrame(""), podFrame("")},
+			want:   "no event id to resume from",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := resumingServer(t, tt.frames...)
+			client, _ := streamForTest(t, server.URL)
+
+			select {
+			case gap := <-client.Gaps():
+				assert.Equal(t, tt.want, gap.Reason)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for gap")
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package kubeview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy describes how failed KubeView requests are retried: exponential
+// backoff starting at InitialDelay, growing by Multiplier up to MaxDelay, with
+// each delay randomised by up to ±Jitter of its value.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  4,
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// Delay returns the time to wait after the given failed attempt, counting from 1.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	return time.Duration(delay)
+}
+
+// Wait blocks for the delay following the given failed attempt, returning early
+// with the context's error if it is cancelled.
+func (p RetryPolicy) Wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.Delay(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// StatusError is returned when the KubeView API responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// isRetryable reports whether a failed request may succeed if repeated: server
+// errors and network failures are retried, client errors and cancellations are not.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState string
+
+// Circuit breaker states.
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// CircuitOpenError is returned instead of performing a request while the circuit breaker is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("kubeview circuit breaker is open, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// CircuitBreaker stops requests to KubeView after Threshold consecutive failures.
+// Once Cooldown has passed it lets a single trial request through: success closes
+// the circuit again, failure re-opens it. It is safe for concurrent use.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     BreakerState
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker. A threshold of zero or less disables it.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a request may be attempted, returning a *CircuitOpenError if not.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		elapsed := b.now().Sub(b.openedAt)
+		if elapsed < b.cooldown {
+			return &CircuitOpenError{RetryAfter: b.cooldown - elapsed}
+		}
+		b.state = BreakerHalfOpen
+		b.openedAt = b.now()
+		return nil
+	case BreakerHalfOpen:
+		// A trial request is in flight. Should it never report back (for example
+		// because it was cancelled), allow another one after a further cooldown.
+		elapsed := b.now().Sub(b.openedAt)
+		if elapsed < b.cooldown {
+			return &CircuitOpenError{RetryAfter: b.cooldown - elapsed}
+		}
+		b.openedAt = b.now()
+		return nil
+	default:
+		return nil
+	}
+}
+
+// RecordSuccess closes the circuit and resets the failure count.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.state = BreakerClosed
+}
+
+// RecordFailure counts a failed request, opening the circuit once the threshold is reached.
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 {
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// withRetry runs op under the client's retry policy and circuit breaker.
+func (c *Client) withRetry(ctx context.Context, op func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.Allow(); err != nil {
+			return err
+		}
+
+		err := op(ctx)
+		if err == nil || !isRetryable(err) {
+			// KubeView answered (possibly with a client error); cancellations say nothing about its health.
+			if ctx.Err() == nil {
+				c.breaker.RecordSuccess()
+			}
+			return err
+		}
+		c.breaker.RecordFailure()
+
+		if attempt >= c.retryPolicy.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		if waitErr := c.retryPolicy.Wait(ctx, attempt); waitErr != nil {
+			return errors.Join(err, waitErr)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package kubeview
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   2,
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, Jitter: 0.2}
+
+	for attempt, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		delay := policy.Delay(attempt)
+		assert.GreaterOrEqual(t, delay, time.Duration(float64(base)*0.8), "attempt %d", attempt)
+		assert.LessOrEqual(t, delay, time.Duration(float64(base)*1.2), "attempt %d", attempt)
+	}
+	assert.Equal(t, time.Second, policy.Delay(10), "delay must be capped at MaxDelay")
+}
+
+func TestRetryPolicy_WaitHonoursCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := RetryPolicy{InitialDelay: time.Hour}.Wait(ctx, 1)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	require.NoError(t, breaker.Allow())
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerClosed, breaker.State())
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, breaker.Allow(), &openErr)
+
+	// After the cooldown a single trial request is let through.
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	require.NoError(t, breaker.Allow())
+	require.ErrorAs(t, breaker.Allow(), &openErr)
+
+	// A failed trial re-opens the circuit, a successful one closes it.
+	breaker.RecordFailure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	now = now.Add(time.Minute)
+	require.NoError(t, breaker.Allow())
+	breaker.RecordSuccess()
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestClient_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"namespaces": ["default"]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithRetryPolicy(fastRetryPolicy()))
+	result, err := client.ListNamespaces(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, result.Namespaces)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, BreakerClosed, client.CircuitState())
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithRetryPolicy(fastRetryPolicy()))
+	_, err := client.FetchNamespaceResources(context.Background(), "restricted", "test-client")
+
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestClient_CircuitBreakerOpensAfterRepeatedFailures(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL,
+		WithRetryPolicy(fastRetryPolicy()),
+		WithCircuitBreaker(NewCircuitBreaker(3, time.Minute)),
+	)
+
+	_, err := client.ListNamespaces(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, BreakerOpen, client.CircuitState())
+
+	// Further requests fail fast without reaching the server.
+	_, err = client.ListNamespaces(context.Background())
+	var openErr *CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, int32(3), calls.Load())
+}
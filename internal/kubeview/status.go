@@ -0,0 +1,96 @@
+package kubeview
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ServerStatus represents the response from the /api/status endpoint.
+type ServerStatus struct {
+	Version     string `json:"version"`
+	BuildInfo   string `json:"buildInfo"`
+	ClusterHost string `json:"clusterHost"`
+	Mode        string `json:"mode"`
+}
+
+// StreamStatus describes the SSE connection.
+type StreamStatus struct {
+	Connected bool `json:"connected"`
+	// Reconnects counts the connections established after the first one.
+	Reconnects  int64      `json:"reconnects"`
+	LastEventID string     `json:"last_event_id,omitempty"`
+	LastEventAt *time.Time `json:"last_event_at,omitempty"`
+	LastPingAt  *time.Time `json:"last_ping_at,omitempty"`
+}
+
+// streamState tracks the SSE connection for StreamStatus.
+type streamState struct {
+	mu          sync.Mutex
+	connected   bool
+	connections int64
+	lastEventAt time.Time
+	lastPingAt  time.Time
+}
+
+func (s *streamState) markConnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = true
+	s.connections++
+}
+
+func (s *streamState) markDisconnected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = false
+}
+
+func (s *streamState) markEvent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEventAt = time.Now()
+}
+
+func (s *streamState) markPing() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPingAt = time.Now()
+}
+
+// StreamStatus returns the state of the SSE connection.
+func (c *Client) StreamStatus() StreamStatus {
+	lastEventID, _ := c.position.get()
+
+	c.stream.mu.Lock()
+	defer c.stream.mu.Unlock()
+	status := StreamStatus{
+		Connected:   c.stream.connected,
+		Reconnects:  max(c.stream.connections-1, 0),
+		LastEventID: lastEventID,
+	}
+	if !c.stream.lastEventAt.IsZero() {
+		lastEventAt := c.stream.lastEventAt
+		status.LastEventAt = &lastEventAt
+	}
+	if !c.stream.lastPingAt.IsZero() {
+		lastPingAt := c.stream.lastPingAt
+		status.LastPingAt = &lastPingAt
+	}
+	return status
+}
+
+// ServerStatus fetches the version and build of the KubeView server from the KubeView API. It backs health
+// checks and the status endpoint, so it makes a single attempt and is not counted by the circuit breaker.
+func (c *Client) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+	ctx, span := c.tracer.Start(ctx, "ServerStatus")
+	defer span.End()
+
+	var result ServerStatus
+	baseURL, _ := c.endpoint.get()
+	if err := c.getJSONOnce(ctx, "status", fmt.Sprintf("%s/api/status", baseURL), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
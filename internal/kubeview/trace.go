@@ -0,0 +1,57 @@
+package kubeview
+
+import (
+	"context"
+	"io"
+
+	"github.com/r3labs/sse/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startEventSpan starts the span in which an SSE message is received. Each message starts a trace
+// of its own, which the spans processing the event link to.
+func (c *Client) startEventSpan(ctx context.Context, msg *sse.Event) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "ReceiveEvent",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingOperationReceive,
+			semconv.MessagingMessageID(string(msg.ID)),
+			attribute.String("kubeview.event", string(msg.Event)),
+		))
+}
+
+// eventAttributes describes the resource an event is about.
+func eventAttributes(event Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("event.type", event.Type),
+		attribute.String("resource.kind", event.Object.Kind),
+		attribute.String("resource.namespace", event.Object.Metadata.Namespace),
+		attribute.String("resource.name", event.Object.Metadata.Name),
+		attribute.String("resource.uid", event.Object.Metadata.UID),
+	}
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
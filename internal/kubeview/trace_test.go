@@ -0,0 +1,150 @@
+package kubeview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedClient(t *testing.T, baseURL string) (*Client, *tracetest.SpanRecorder) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdkTrace.NewTracerProvider(sdkTrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+
+	client := NewClient(baseURL)
+	client.tracer = provider.Tracer("test")
+	return client, recorder
+}
+
+func spansNamed(recorder *tracetest.SpanRecorder, name string) []sdkTrace.ReadOnlySpan {
+	var spans []sdkTrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func spanAttributes(span sdkTrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestClient_TracesRequests(t *testing.T) {
+	body := `{"namespaces": ["default"]}`
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client, recorder := newTracedClient(t, server.URL)
+
+	_, err := client.ListNamespaces(context.Background())
+	require.NoError(t, err)
+
+	requests := spansNamed(recorder, "HTTP GET")
+	require.Len(t, requests, 1)
+	request := requests[0]
+	parents := spansNamed(recorder, "ListNamespaces")
+	require.Len(t, parents, 1, "ListNamespaces span should be ended")
+	assert.Equal(t, parents[0].SpanContext().SpanID(), request.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindClient, request.SpanKind())
+
+	attrs := spanAttributes(request)
+	assert.Equal(t, "GET", attrs["http.method"].AsString())
+	assert.Equal(t, server.URL+"/api/namespaces", attrs["http.url"].AsString())
+	assert.Equal(t, int64(http.StatusOK), attrs["http.status_code"].AsInt64())
+	assert.Equal(t, int64(len(body)), attrs["http.response_content_length"].AsInt64())
+
+	want := fmt.Sprintf("00-%s-%s-01", request.SpanContext().TraceID(), request.SpanContext().SpanID())
+	assert.Equal(t, want, traceparent)
+}
+
+func TestClient_TracesFailedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client, recorder := newTracedClient(t, server.URL)
+
+	_, err := client.ServerStatus(context.Background())
+	require.Error(t, err)
+
+	requests := spansNamed(recorder, "HTTP GET")
+	require.Len(t, requests, 1, "a 404 should not be retried")
+	assert.Equal(t, int64(http.StatusNotFound), spanAttributes(requests[0])["http.status_code"].AsInt64())
+	assert.Equal(t, codes.Error, requests[0].Status().Code)
+}
+
+func TestClient_TracesStreamedEvents(t *testing.T) {
+	traceparent := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		_, _ = fmt.Fprintf(w, "event: bogus\ndata: {}\n\n")
+		_, _ = fmt.Fprintf(w, "id: 7\nevent: add\n"+
+			"data: {\"kind\":\"Pod\",\"metadata\":{\"name\":\"web\",\"namespace\":\"shop\",\"uid\":\"web-uid\"}}\n\n")
+		flusher.Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client, recorder := newTracedClient(t, server.URL)
+	eventChan := make(chan Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.StreamUpdates(ctx, "test-client", eventChan)
+
+	var event Event
+	select {
+	case event = <-eventChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	require.True(t, event.SpanContext.IsValid())
+
+	require.Eventually(t, func() bool {
+		return len(spansNamed(recorder, "ReceiveEvent")) == 2
+	}, time.Second, 10*time.Millisecond)
+	received := spansNamed(recorder, "ReceiveEvent")
+	rejected, accepted := received[0], received[1]
+
+	assert.Equal(t, codes.Error, rejected.Status().Code)
+	assert.Equal(t, event.SpanContext, accepted.SpanContext())
+	assert.Equal(t, trace.SpanKindConsumer, accepted.SpanKind())
+	attrs := spanAttributes(accepted)
+	assert.Equal(t, "7", attrs["messaging.message.id"].AsString())
+	assert.Equal(t, "Pod", attrs["resource.kind"].AsString())
+	assert.Equal(t, "shop", attrs["resource.namespace"].AsString())
+	assert.Equal(t, "web-uid", attrs["resource.uid"].AsString())
+
+	connections := spansNamed(recorder, "HTTP GET")
+	require.Len(t, connections, 1)
+	want := fmt.Sprintf("00-%s-%s-01", connections[0].SpanContext().TraceID(), connections[0].SpanContext().SpanID())
+	assert.Equal(t, want, <-traceparent)
+}
@@ -2,93 +2,529 @@ package neo4j
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"kube-kg/internal/config"
 	"kube-kg/internal/graph"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	driverconfig "github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ResourceLabel is the label shared by every node that represents a Kubernetes resource.
+// The uid uniqueness constraint and the lookup indexes are defined on this label.
+const ResourceLabel = "KubernetesResource"
+
+// reconnectDrainTimeout is how long the previous driver is kept open at most after Reconnect, so that
+// the transactions started on it can finish.
+const reconnectDrainTimeout = 30 * time.Second
+
 // Client wraps the Neo4j driver.
 type Client struct {
-	driver neo4j.DriverWithContext
+	// conn and poolSize are replaced by Reconnect.
+	conn      *connection
+	poolSize  int
+	driverMu  sync.RWMutex
+	batchSize int
+	tracer    trace.Tracer
+	// queryDuration records the latency of each operation, including every query it runs.
+	queryDuration metric.Float64Histogram
 }
 
 // NewClient creates a new Neo4j client and connects to the database.
 func NewClient(ctx context.Context, cfg *config.Config) (*Client, error) {
+	driver, poolSize, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := cfg.Neo4jBatchSize
+	if batchSize <= 0 {
+		batchSize = config.DefaultNeo4jBatchSize
+	}
+
+	queryDuration, err := otel.Meter("kube-kg/internal/neo4j").Float64Histogram("neo4j.query.duration",
+		metric.WithDescription("Time taken by Neo4j operations, by operation and result"),
+		metric.WithUnit("s"))
+	if err != nil {
+		slog.WarnContext(ctx, "failed to create metric", "name", "neo4j.query.duration", "err", err)
+	}
+
+	client := &Client{
+		conn:          newConnection(driver),
+		batchSize:     batchSize,
+		poolSize:      poolSize,
+		tracer:        otel.Tracer("kube-kg/internal/neo4j"),
+		queryDuration: queryDuration,
+	}
+
+	if err := client.EnsureSchema(ctx); err != nil {
+		if closeErr := driver.Close(ctx); closeErr != nil {
+			slog.WarnContext(ctx, "failed to close Neo4j driver", "err", closeErr)
+		}
+		return nil, fmt.Errorf("failed to bootstrap Neo4j schema: %w", err)
+	}
+
+	return client, nil
+}
+
+// connect creates a driver for the URI, credentials and pool size of cfg and verifies that it can connect.
+func connect(ctx context.Context, cfg *config.Config) (neo4j.DriverWithContext, int, error) {
+	poolSize := cfg.Neo4jMaxPoolSize
+	if poolSize <= 0 {
+		poolSize = config.DefaultNeo4jMaxConnectionPoolSize
+	}
+
 	driver, err := neo4j.NewDriverWithContext(
 		cfg.Neo4jURI,
 		neo4j.BasicAuth(cfg.Neo4jUser, cfg.Neo4jPassword, ""),
+		func(c *driverconfig.Config) {
+			c.MaxConnectionPoolSize = poolSize
+		},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Neo4j driver: %w", err)
+		return nil, 0, fmt.Errorf("failed to create Neo4j driver: %w", err)
 	}
 
 	if err := driver.VerifyConnectivity(ctx); err != nil {
-		return nil, fmt.Errorf("failed to verify Neo4j connectivity: %w", err)
+		_ = driver.Close(ctx)
+		return nil, 0, fmt.Errorf("failed to verify Neo4j connectivity: %w", err)
 	}
+	return driver, poolSize, nil
+}
+
+// Reconnect connects to Neo4j with the URI, credentials and pool size of cfg, bootstraps the schema and
+// switches to the new connection. The current connection is kept if any of this fails. Transactions
+// already begun finish on the previous driver, which is closed once they have, or after
+// reconnectDrainTimeout at the latest.
+func (c *Client) Reconnect(ctx context.Context, cfg *config.Config) (err error) {
+	ctx, end := c.startOperation(ctx, "Reconnect", "reconnect")
+	defer end(&err)
+
+	driver, poolSize, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.ensureSchema(ctx, driver); err != nil {
+		if closeErr := driver.Close(ctx); closeErr != nil {
+			slog.WarnContext(ctx, "failed to close Neo4j driver", "err", closeErr)
+		}
+		return fmt.Errorf("failed to bootstrap Neo4j schema: %w", err)
+	}
+
+	c.driverMu.Lock()
+	previous := c.conn
+	c.conn = newConnection(driver)
+	c.poolSize = poolSize
+	c.driverMu.Unlock()
+
+	go previous.closeWhenDrained(context.WithoutCancel(ctx), reconnectDrainTimeout)
+	return nil
+}
 
-	return &Client{driver: driver}, nil
+// currentDriver returns the driver new sessions are opened with.
+func (c *Client) currentDriver() neo4j.DriverWithContext {
+	c.driverMu.RLock()
+	defer c.driverMu.RUnlock()
+	return c.conn.driver
+}
+
+// acquireConnection returns the current connection with a transaction recorded on it. Recording it while
+// holding driverMu guarantees that Reconnect, which retires the connection, counts the transaction.
+func (c *Client) acquireConnection() *connection {
+	c.driverMu.RLock()
+	defer c.driverMu.RUnlock()
+	c.conn.acquire()
+	return c.conn
+}
+
+// MaxConnectionPoolSize returns the maximum number of connections the driver opens to Neo4j.
+// Every open transaction holds one of them.
+func (c *Client) MaxConnectionPoolSize() int {
+	c.driverMu.RLock()
+	defer c.driverMu.RUnlock()
+	return c.poolSize
 }
 
 func (c *Client) VerifyConnectivity(ctx context.Context) error {
-	return c.driver.VerifyConnectivity(ctx)
+	return c.currentDriver().VerifyConnectivity(ctx)
 }
 
 // Close closes the Neo4j driver.
 func (c *Client) Close(ctx context.Context) error {
-	return c.driver.Close(ctx)
+	return c.currentDriver().Close(ctx)
 }
 
-// Begin starts a new transaction.
-func (c *Client) Begin(ctx context.Context) (neo4j.ExplicitTransaction, error) {
-	session := c.driver.NewSession(ctx, neo4j.SessionConfig{})
+// observe records the latency of an operation that started at start. It is meant to be deferred
+// with a pointer to the operation's error.
+func (c *Client) observe(ctx context.Context, operation string, start time.Time, err *error) {
+	result := "ok"
+	if *err != nil {
+		result = "error"
+	}
+	c.queryDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("result", result),
+	))
+}
+
+// Begin starts a new transaction in a session of its own. Closing the transaction also closes the session.
+func (c *Client) Begin(ctx context.Context) (_ neo4j.ExplicitTransaction, err error) {
+	ctx, end := c.startOperation(ctx, "Begin", "begin")
+	defer end(&err)
+
+	conn := c.acquireConnection()
+	session := conn.driver.NewSession(ctx, neo4j.SessionConfig{})
 	tx, err := session.BeginTransaction(ctx)
 	if err != nil {
+		if closeErr := session.Close(ctx); closeErr != nil {
+			slog.ErrorContext(ctx, "failed to close session", "err", closeErr)
+		}
+		conn.release()
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return tx, nil
+	return &sessionTransaction{ExplicitTransaction: tx, session: session, client: c, conn: conn}, nil
+}
+
+// sessionTransaction is a transaction that owns the session it runs in.
+type sessionTransaction struct {
+	neo4j.ExplicitTransaction
+	session neo4j.SessionWithContext
+	client  *Client
+	// conn is released when the transaction is first closed.
+	conn    *connection
+	release sync.Once
+}
+
+// Commit commits the transaction.
+func (t *sessionTransaction) Commit(ctx context.Context) (err error) {
+	ctx, end := t.client.startOperation(ctx, "Commit", "commit")
+	defer end(&err)
+	return t.ExplicitTransaction.Commit(ctx)
+}
+
+// Rollback rolls the transaction back.
+func (t *sessionTransaction) Rollback(ctx context.Context) (err error) {
+	ctx, end := t.client.startOperation(ctx, "Rollback", "rollback")
+	defer end(&err)
+	return t.ExplicitTransaction.Rollback(ctx)
+}
+
+// Close closes the transaction, rolling it back if it is still open, and then its session.
+func (t *sessionTransaction) Close(ctx context.Context) error {
+	txErr := t.ExplicitTransaction.Close(ctx)
+	sessionErr := t.session.Close(ctx)
+	t.release.Do(t.conn.release)
+	return errors.Join(txErr, sessionErr)
 }
 
 // MergeNode merges a node in the graph.
 func (c *Client) MergeNode(ctx context.Context, tx neo4j.ExplicitTransaction, node graph.Node) error {
+	_, err := c.MergeNodes(ctx, tx, []graph.Node{node})
+	return err
+}
+
+// MergeNodes merges a set of nodes in the graph. Nodes are grouped by label and
+// written with one UNWIND query per batch of at most batchSize rows.
+//
+// The properties of a node are replaced by those written, so that labels and annotations
+// removed from the resource are removed from the node too. A node whose stored
+// resourceVersion is newer than the one being written keeps its properties; only its
+// syncGeneration is updated, so that a sync does not prune it. The uids of those
+// rejected writes are returned.
+func (c *Client) MergeNodes(ctx context.Context, tx neo4j.ExplicitTransaction,
+	nodes []graph.Node) (_ []string, err error) {
+	ctx, end := c.startOperation(ctx, "MergeNodes", "merge_nodes")
+	defer end(&err)
 	query := `
-	MERGE (n:%s {uid: $uid})
-	SET n += $props
+	UNWIND $rows AS row
+	MERGE (n:KubernetesResource {uid: row.uid})
+	SET n:%s
+	WITH n, row, coalesce(row.props.syncGeneration, n.syncGeneration) AS generation,
+		n.resourceVersion IS NULL OR row.props.resourceVersion IS NULL
+		OR n.resourceVersion <= row.props.resourceVersion AS fresh
+	FOREACH (_ IN CASE WHEN fresh THEN [1] ELSE [] END |
+		SET n = row.props, n.uid = row.uid, n.syncGeneration = generation)
+	FOREACH (_ IN CASE WHEN fresh THEN [] ELSE [1] END | SET n.syncGeneration = generation)
+	RETURN collect(CASE WHEN fresh THEN null ELSE row.uid END) AS rejected
 	`
-	query = fmt.Sprintf(query, node.Label)
 
-	params := map[string]interface{}{
-		"uid":   node.ID,
-		"props": node.Properties,
+	byLabel := make(map[string][]map[string]interface{})
+	for _, node := range nodes {
+		byLabel[node.Label] = append(byLabel[node.Label], map[string]interface{}{
+			"uid":   node.ID,
+			"props": node.Properties,
+		})
 	}
 
-	_, err := tx.Run(ctx, query, params)
-	return err
+	var rejected []string
+	for _, label := range slices.Sorted(maps.Keys(byLabel)) {
+		for rows := range slices.Chunk(byLabel[label], c.batchSize) {
+			params := map[string]interface{}{
+				"rows": rows,
+			}
+			record, err := c.single(ctx, tx, "merge_nodes", fmt.Sprintf(query, label), params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge %d %s nodes: %w", len(rows), label, err)
+			}
+			uids, _ := record.Values[0].([]interface{})
+			for _, uid := range uids {
+				if uid, ok := uid.(string); ok {
+					rejected = append(rejected, uid)
+				}
+			}
+		}
+	}
+	return rejected, nil
 }
 
 // MergeRelationship merges a relationship in the graph.
 func (c *Client) MergeRelationship(ctx context.Context, tx neo4j.ExplicitTransaction, rel graph.Relationship) error {
+	return c.MergeRelationships(ctx, tx, []graph.Relationship{rel})
+}
+
+// MergeRelationships merges a set of relationships in the graph. Relationships are
+// grouped by type and written with one UNWIND query per batch of at most batchSize rows.
+func (c *Client) MergeRelationships(ctx context.Context, tx neo4j.ExplicitTransaction,
+	rels []graph.Relationship) (err error) {
+	ctx, end := c.startOperation(ctx, "MergeRelationships", "merge_relationships")
+	defer end(&err)
+	query := `
+	UNWIND $rows AS row
+	MATCH (source:KubernetesResource {uid: row.sourceId})
+	MATCH (target:KubernetesResource {uid: row.targetId})
+	MERGE (source)-[r:%s]->(target)
+	SET r += row.props
+	`
+
+	byType := make(map[string][]map[string]interface{})
+	for _, rel := range rels {
+		props := rel.Properties
+		if props == nil {
+			props = map[string]interface{}{}
+		}
+		byType[rel.Type] = append(byType[rel.Type], map[string]interface{}{
+			"sourceId": rel.SourceID,
+			"targetId": rel.TargetID,
+			"props":    props,
+		})
+	}
+
+	for _, relType := range slices.Sorted(maps.Keys(byType)) {
+		for rows := range slices.Chunk(byType[relType], c.batchSize) {
+			params := map[string]interface{}{
+				"rows": rows,
+			}
+			if _, err := c.run(ctx, tx, "merge_relationships", fmt.Sprintf(query, relType), params); err != nil {
+				return fmt.Errorf("failed to merge %d %s relationships: %w", len(rows), relType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// OutgoingRelationships returns the relationships that start at the node with the given uid.
+func (c *Client) OutgoingRelationships(ctx context.Context, tx neo4j.ExplicitTransaction, uid string) ([]graph.Relationship, error) {
+	query := `
+	MATCH (:KubernetesResource {uid: $uid})-[r]->(other:KubernetesResource)
+	RETURN type(r) AS type, other.uid AS otherId
+	`
+	return c.relationshipsOf(ctx, tx, "OutgoingRelationships", query, uid, false)
+}
+
+// IncomingRelationships returns the relationships that end at the node with the given uid.
+func (c *Client) IncomingRelationships(ctx context.Context, tx neo4j.ExplicitTransaction, uid string) ([]graph.Relationship, error) {
 	query := `
-	MATCH (source {uid: $sourceId})
-	MATCH (target {uid: $targetId})
-	MERGE (source)-[:%s]->(target)
+	MATCH (:KubernetesResource {uid: $uid})<-[r]-(other:KubernetesResource)
+	RETURN type(r) AS type, other.uid AS otherId
+	`
+	return c.relationshipsOf(ctx, tx, "IncomingRelationships", query, uid, true)
+}
+
+func (c *Client) relationshipsOf(ctx context.Context, tx neo4j.ExplicitTransaction, name, query, uid string,
+	incoming bool) (_ []graph.Relationship, err error) {
+	ctx, end := c.startOperation(ctx, name, "read_relationships")
+	defer end(&err)
+	records, err := c.run(ctx, tx, "read_relationships", query, map[string]interface{}{"uid": uid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relationships of %s: %w", uid, err)
+	}
+
+	rels := make([]graph.Relationship, 0, len(records))
+	for _, record := range records {
+		relType, _ := record.Values[0].(string)
+		otherID, _ := record.Values[1].(string)
+		rel := graph.Relationship{SourceID: uid, TargetID: otherID, Type: relType}
+		if incoming {
+			rel.SourceID, rel.TargetID = otherID, uid
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+// DeleteRelationships deletes a set of relationships from the graph, grouped by type
+// and written in batches like MergeRelationships.
+func (c *Client) DeleteRelationships(ctx context.Context, tx neo4j.ExplicitTransaction,
+	rels []graph.Relationship) (err error) {
+	ctx, end := c.startOperation(ctx, "DeleteRelationships", "delete_relationships")
+	defer end(&err)
+	query := `
+	UNWIND $rows AS row
+	MATCH (:KubernetesResource {uid: row.sourceId})-[r:%s]->(:KubernetesResource {uid: row.targetId})
+	DELETE r
+	`
+
+	byType := make(map[string][]map[string]interface{})
+	for _, rel := range rels {
+		byType[rel.Type] = append(byType[rel.Type], map[string]interface{}{
+			"sourceId": rel.SourceID,
+			"targetId": rel.TargetID,
+		})
+	}
+
+	for _, relType := range slices.Sorted(maps.Keys(byType)) {
+		for rows := range slices.Chunk(byType[relType], c.batchSize) {
+			params := map[string]interface{}{
+				"rows": rows,
+			}
+			if _, err := c.run(ctx, tx, "delete_relationships", fmt.Sprintf(query, relType), params); err != nil {
+				return fmt.Errorf("failed to delete %d %s relationships: %w", len(rows), relType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ReconcileResult reports the changes made by ReconcileRelationships.
+type ReconcileResult struct {
+	Deleted int
+	Created int
+}
+
+// ReconcileRelationships makes the outgoing relationships of the node with the given uid
+// match desired. Only relationships whose type is listed in types are considered owned
+// by the node and may be deleted; a nil types reconciles every outgoing relationship.
+// All desired relationships are merged so that their properties are refreshed.
+func (c *Client) ReconcileRelationships(ctx context.Context, tx neo4j.ExplicitTransaction, uid string,
+	desired []graph.Relationship, types []string) (ReconcileResult, error) {
+	existing, err := c.OutgoingRelationships(ctx, tx, uid)
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+	return c.reconcile(ctx, tx, existing, desired, types)
+}
+
+// ReconcileIncomingRelationships is the counterpart of ReconcileRelationships for the
+// relationships that end at the node with the given uid.
+func (c *Client) ReconcileIncomingRelationships(ctx context.Context, tx neo4j.ExplicitTransaction, uid string,
+	desired []graph.Relationship, types []string) (ReconcileResult, error) {
+	existing, err := c.IncomingRelationships(ctx, tx, uid)
+	if err != nil {
+		return ReconcileResult{}, err
+	}
+	return c.reconcile(ctx, tx, existing, desired, types)
+}
+
+func (c *Client) reconcile(ctx context.Context, tx neo4j.ExplicitTransaction, existing, desired []graph.Relationship,
+	types []string) (ReconcileResult, error) {
+	if types != nil {
+		existing = slices.DeleteFunc(existing, func(rel graph.Relationship) bool {
+			return !slices.Contains(types, rel.Type)
+		})
+	}
+
+	stale, missing := graph.DiffRelationships(existing, desired)
+	if err := c.DeleteRelationships(ctx, tx, stale); err != nil {
+		return ReconcileResult{}, err
+	}
+	if err := c.MergeRelationships(ctx, tx, desired); err != nil {
+		return ReconcileResult{}, err
+	}
+	return ReconcileResult{Deleted: len(stale), Created: len(missing)}, nil
+}
+
+// PruneResult reports how many stale graph elements were removed by PruneNamespace.
+type PruneResult struct {
+	Nodes         int64
+	Relationships int64
+}
+
+// PruneScope restricts pruning to part of a namespace. Empty fields match everything.
+type PruneScope struct {
+	Namespace string
+	// Kinds limits pruning to nodes of these kinds and their outgoing relationships.
+	Kinds []string
+	// Name limits pruning to nodes with this name and their outgoing relationships.
+	Name string
+}
+
+// PruneNamespace deletes the nodes and relationships of a namespace whose
+// syncGeneration is older than generation. Elements that were never stamped are
+// treated as belonging to generation zero.
+func (c *Client) PruneNamespace(ctx context.Context, tx neo4j.ExplicitTransaction, namespace string, generation int64) (PruneResult, error) {
+	return c.PruneScope(ctx, tx, PruneScope{Namespace: namespace}, generation)
+}
+
+// PruneScope is like PruneNamespace, but only prunes the nodes within scope and the
+// relationships that start at them.
+func (c *Client) PruneScope(ctx context.Context, tx neo4j.ExplicitTransaction, scope PruneScope,
+	generation int64) (_ PruneResult, err error) {
+	ctx, end := c.startOperation(ctx, "PruneScope", "prune")
+	defer end(&err)
+	inScope := `($kinds IS NULL OR n.kind IN $kinds) AND ($name IS NULL OR n.name = $name)`
+	relQuery := `
+	MATCH (n:KubernetesResource {namespace: $namespace})-[r]->()
+	WHERE ` + inScope + ` AND coalesce(r.syncGeneration, 0) < $generation
+	DELETE r
+	RETURN count(r) AS pruned
+	`
+	nodeQuery := `
+	MATCH (n:KubernetesResource {namespace: $namespace})
+	WHERE ` + inScope + ` AND coalesce(n.syncGeneration, 0) < $generation
+	DETACH DELETE n
+	RETURN count(n) AS pruned
 	`
-	query = fmt.Sprintf(query, rel.Type)
 
 	params := map[string]interface{}{
-		"sourceId": rel.SourceID,
-		"targetId": rel.TargetID,
+		"namespace":  scope.Namespace,
+		"kinds":      nil,
+		"name":       nil,
+		"generation": generation,
+	}
+	if len(scope.Kinds) > 0 {
+		params["kinds"] = scope.Kinds
+	}
+	if scope.Name != "" {
+		params["name"] = scope.Name
 	}
 
-	_, err := tx.Run(ctx, query, params)
-	return err
+	var result PruneResult
+	if result.Relationships, err = c.runCount(ctx, tx, "prune", relQuery, params); err != nil {
+		return PruneResult{}, fmt.Errorf("failed to prune relationships in namespace %s: %w", scope.Namespace, err)
+	}
+	if result.Nodes, err = c.runCount(ctx, tx, "prune", nodeQuery, params); err != nil {
+		return PruneResult{}, fmt.Errorf("failed to prune nodes in namespace %s: %w", scope.Namespace, err)
+	}
+	return result, nil
 }
 
 // DeleteNode deletes a node from the graph.
-func (c *Client) DeleteNode(ctx context.Context, uid string) error {
+func (c *Client) DeleteNode(ctx context.Context, uid string) (err error) {
+	ctx, end := c.startOperation(ctx, "DeleteNode", "delete_node")
+	defer end(&err)
 	query := `
-	MATCH (n {uid: $uid})
+	MATCH (n:KubernetesResource {uid: $uid})
 	DETACH DELETE n
 	`
 
@@ -96,7 +532,135 @@ func (c *Client) DeleteNode(ctx context.Context, uid string) error {
 		"uid": uid,
 	}
 
-	session := c.driver.NewSession(ctx, neo4j.SessionConfig{})
-	_, err := session.Run(ctx, query, params)
+	session := c.currentDriver().NewSession(ctx, neo4j.SessionConfig{})
+	defer func() {
+		if err := session.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to close session", "err", err)
+		}
+	}()
+	_, err = c.run(ctx, sessionRunner{session}, "delete_node", query, params)
 	return err
 }
+
+// DeleteNodes deletes the nodes with the given uids, together with their relationships, in batches.
+func (c *Client) DeleteNodes(ctx context.Context, tx neo4j.ExplicitTransaction, uids []string) (err error) {
+	ctx, end := c.startOperation(ctx, "DeleteNodes", "delete_nodes")
+	defer end(&err)
+	query := `
+	UNWIND $uids AS uid
+	MATCH (n:KubernetesResource {uid: uid})
+	DETACH DELETE n
+	`
+
+	for batch := range slices.Chunk(uids, c.batchSize) {
+		params := map[string]interface{}{
+			"uids": batch,
+		}
+		if _, err := c.run(ctx, tx, "delete_nodes", query, params); err != nil {
+			return fmt.Errorf("failed to delete %d nodes: %w", len(batch), err)
+		}
+	}
+	return nil
+}
+
+// NamespaceNodes returns the properties of every node in a namespace, keyed by uid.
+func (c *Client) NamespaceNodes(ctx context.Context, tx neo4j.ExplicitTransaction,
+	namespace string) (_ map[string]map[string]any, err error) {
+	ctx, end := c.startOperation(ctx, "NamespaceNodes", "read_nodes")
+	defer end(&err)
+	query := `
+	MATCH (n:KubernetesResource {namespace: $namespace})
+	RETURN n.uid AS uid, properties(n) AS props
+	`
+
+	records, err := c.run(ctx, tx, "read_nodes", query, map[string]interface{}{"namespace": namespace})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nodes in namespace %s: %w", namespace, err)
+	}
+	nodes := make(map[string]map[string]any, len(records))
+	for _, record := range records {
+		uid, _ := record.Values[0].(string)
+		props, _ := record.Values[1].(map[string]any)
+		nodes[uid] = props
+	}
+	return nodes, nil
+}
+
+// GraphCounts holds the number of nodes per label and relationships per type.
+type GraphCounts struct {
+	Nodes         map[string]int64 `json:"nodes"`
+	Relationships map[string]int64 `json:"relationships"`
+}
+
+// GraphCounts counts the nodes of each label and the relationships of each type. The counts
+// are read from Neo4j's count store, so they do not scan the graph.
+func (c *Client) GraphCounts(ctx context.Context) (_ GraphCounts, err error) {
+	ctx, end := c.startOperation(ctx, "GraphCounts", "graph_counts")
+	defer end(&err)
+
+	session := c.currentDriver().NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer func() {
+		if err := session.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to close session", "err", err)
+		}
+	}()
+
+	counts, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		counts := GraphCounts{Nodes: make(map[string]int64), Relationships: make(map[string]int64)}
+		labels, err := c.collectStrings(ctx, tx, "graph_counts", "CALL db.labels() YIELD label RETURN label")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list labels: %w", err)
+		}
+		for _, label := range labels {
+			query := fmt.Sprintf("MATCH (n:%s) RETURN count(n)", quoteIdentifier(label))
+			if counts.Nodes[label], err = c.runCount(ctx, tx, "graph_counts", query, nil); err != nil {
+				return nil, fmt.Errorf("failed to count nodes labelled %s: %w", label, err)
+			}
+		}
+		types, err := c.collectStrings(ctx, tx, "graph_counts", "CALL db.relationshipTypes() YIELD relationshipType RETURN relationshipType")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list relationship types: %w", err)
+		}
+		for _, relType := range types {
+			query := fmt.Sprintf("MATCH ()-[r:%s]->() RETURN count(r)", quoteIdentifier(relType))
+			if counts.Relationships[relType], err = c.runCount(ctx, tx, "graph_counts", query, nil); err != nil {
+				return nil, fmt.Errorf("failed to count %s relationships: %w", relType, err)
+			}
+		}
+		return counts, nil
+	})
+	if err != nil {
+		return GraphCounts{}, err
+	}
+	return counts.(GraphCounts), nil
+}
+
+// collectStrings runs a query that returns a single string column and returns its values.
+func (c *Client) collectStrings(ctx context.Context, tx runner, operation, query string) ([]string, error) {
+	records, err := c.run(ctx, tx, operation, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(records))
+	for _, record := range records {
+		value, _ := record.Values[0].(string)
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// quoteIdentifier quotes a label or relationship type for use in a Cypher query.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// runCount runs a query that returns a single integer column and returns its value.
+func (c *Client) runCount(ctx context.Context, tx runner, operation, query string,
+	params map[string]interface{}) (int64, error) {
+	record, err := c.single(ctx, tx, operation, query, params)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := record.Values[0].(int64)
+	return count, nil
+}
@@ -3,6 +3,7 @@ package neo4j
 import (
 	"context"
 	"kube-kg/internal/config"
+	"kube-kg/internal/graph"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -76,3 +77,306 @@ func TestClient_RunCypher(t *testing.T) {
 
 	require.NoError(t, tx.Commit(ctx))
 }
+
+func TestClient_MergeNodesAndRelationships(t *testing.T) {
+	ctx := context.Background()
+
+	neo4jContainer, err := neo4j.Run(ctx, "neo4j:5", neo4j.WithAdminPassword("password"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, neo4jContainer.Terminate(ctx))
+	}()
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	// A small batch size forces the writes to be split into several UNWIND queries.
+	cfg := &config.Config{
+		Neo4jURI:       uri,
+		Neo4jUser:      "neo4j",
+		Neo4jPassword:  "password",
+		Neo4jBatchSize: 2,
+	}
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.Close(ctx))
+	}()
+
+	nodes := []graph.Node{
+		{ID: "pod-1", Label: "Pod", Properties: map[string]interface{}{"uid": "pod-1", "name": "pod-1"}},
+		{ID: "pod-2", Label: "Pod", Properties: map[string]interface{}{"uid": "pod-2", "name": "pod-2"}},
+		{ID: "pod-3", Label: "Pod", Properties: map[string]interface{}{"uid": "pod-3", "name": "pod-3"}},
+		{ID: "svc-1", Label: "Service", Properties: map[string]interface{}{"uid": "svc-1", "name": "svc-1"}},
+	}
+	rels := []graph.Relationship{
+		{SourceID: "svc-1", TargetID: "pod-1", Type: "SELECTS"},
+		{SourceID: "svc-1", TargetID: "pod-2", Type: "SELECTS"},
+		{SourceID: "svc-1", TargetID: "pod-3", Type: "SELECTS"},
+	}
+
+	tx, err := client.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+
+	_, err = client.MergeNodes(ctx, tx, nodes)
+	require.NoError(t, err)
+	require.NoError(t, client.MergeRelationships(ctx, tx, rels))
+	// Merging the same data twice must not create duplicates.
+	_, err = client.MergeNodes(ctx, tx, nodes)
+	require.NoError(t, err)
+	require.NoError(t, client.MergeRelationships(ctx, tx, rels))
+
+	result, err := tx.Run(ctx, "MATCH (n:Pod) RETURN count(n)", nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	assert.Equal(t, int64(3), result.Record().Values[0])
+
+	result, err = tx.Run(ctx, "MATCH (:Service)-[r:SELECTS]->(:Pod) RETURN count(r)", nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	assert.Equal(t, int64(3), result.Record().Values[0])
+
+	namespaced := graph.Node{ID: "cm-1", Label: "ConfigMap", Properties: map[string]interface{}{
+		"uid": "cm-1", "name": "cm-1", "namespace": "team-a",
+	}}
+	_, err = client.MergeNodes(ctx, tx, []graph.Node{namespaced})
+	require.NoError(t, err)
+	namespaceNodes, err := client.NamespaceNodes(ctx, tx, "team-a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]any{"cm-1": namespaced.Properties}, namespaceNodes)
+
+	// Deleting nodes also removes their relationships; unknown uids are ignored.
+	require.NoError(t, client.DeleteNodes(ctx, tx, []string{"pod-1", "pod-2", "missing"}))
+
+	result, err = tx.Run(ctx, "MATCH (:Service)-[r:SELECTS]->(:Pod) RETURN count(r)", nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	assert.Equal(t, int64(1), result.Record().Values[0])
+
+	require.NoError(t, tx.Commit(ctx))
+
+	counts, err := client.GraphCounts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counts.Nodes[ResourceLabel])
+	assert.Equal(t, int64(1), counts.Nodes["Pod"])
+	assert.Equal(t, int64(1), counts.Relationships["SELECTS"])
+}
+
+func TestClient_EnsureSchema(t *testing.T) {
+	ctx := context.Background()
+
+	neo4jContainer, err := neo4j.Run(ctx, "neo4j:5", neo4j.WithAdminPassword("password"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, neo4jContainer.Terminate(ctx))
+	}()
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Neo4jURI:      uri,
+		Neo4jUser:     "neo4j",
+		Neo4jPassword: "password",
+	}
+
+	// NewClient bootstraps the schema; running it again must be a no-op.
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.Close(ctx))
+	}()
+	require.NoError(t, client.EnsureSchema(ctx))
+
+	tx, err := client.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+
+	result, err := tx.Run(ctx, "SHOW CONSTRAINTS YIELD name WHERE name = 'kubernetes_resource_uid' RETURN count(*)", nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	assert.Equal(t, int64(1), result.Record().Values[0])
+
+	result, err = tx.Run(ctx, "SHOW INDEXES YIELD name WHERE name STARTS WITH 'kubernetes_resource_' RETURN count(*)", nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	assert.Equal(t, int64(4), result.Record().Values[0])
+}
+
+func TestClient_ReconcileRelationships(t *testing.T) {
+	ctx := context.Background()
+
+	neo4jContainer, err := neo4j.Run(ctx, "neo4j:5", neo4j.WithAdminPassword("password"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, neo4jContainer.Terminate(ctx))
+	}()
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Neo4jURI:      uri,
+		Neo4jUser:     "neo4j",
+		Neo4jPassword: "password",
+	}
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.Close(ctx))
+	}()
+
+	tx, err := client.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+
+	_, err = client.MergeNodes(ctx, tx, []graph.Node{
+		{ID: "pod", Label: "Pod", Properties: map[string]interface{}{"uid": "pod"}},
+		{ID: "rs", Label: "ReplicaSet", Properties: map[string]interface{}{"uid": "rs"}},
+		{ID: "old-cm", Label: "ConfigMap", Properties: map[string]interface{}{"uid": "old-cm"}},
+		{ID: "new-cm", Label: "ConfigMap", Properties: map[string]interface{}{"uid": "new-cm"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.MergeRelationships(ctx, tx, []graph.Relationship{
+		{SourceID: "pod", TargetID: "rs", Type: "OWNS"},
+		{SourceID: "pod", TargetID: "old-cm", Type: "MOUNTS"},
+	}))
+
+	result, err := client.ReconcileRelationships(ctx, tx, "pod", []graph.Relationship{
+		{SourceID: "pod", TargetID: "rs", Type: "OWNS"},
+		{SourceID: "pod", TargetID: "new-cm", Type: "MOUNTS"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ReconcileResult{Deleted: 1, Created: 1}, result)
+
+	rels, err := client.OutgoingRelationships(ctx, tx, "pod")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []graph.Relationship{
+		{SourceID: "pod", TargetID: "rs", Type: "OWNS"},
+		{SourceID: "pod", TargetID: "new-cm", Type: "MOUNTS"},
+	}, rels)
+
+	// Restricting the owned types leaves the other relationships untouched.
+	result, err = client.ReconcileRelationships(ctx, tx, "pod", nil, []string{"OWNS"})
+	require.NoError(t, err)
+	assert.Equal(t, ReconcileResult{Deleted: 1, Created: 0}, result)
+
+	rels, err = client.OutgoingRelationships(ctx, tx, "pod")
+	require.NoError(t, err)
+	assert.Equal(t, []graph.Relationship{{SourceID: "pod", TargetID: "new-cm", Type: "MOUNTS"}}, rels)
+
+	require.NoError(t, tx.Commit(ctx))
+}
+
+func TestClient_MergeNodesRejectsStaleVersions(t *testing.T) {
+	ctx := context.Background()
+
+	neo4jContainer, err := neo4j.Run(ctx, "neo4j:5", neo4j.WithAdminPassword("password"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, neo4jContainer.Terminate(ctx))
+	}()
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Neo4jURI:      uri,
+		Neo4jUser:     "neo4j",
+		Neo4jPassword: "password",
+	}
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.Close(ctx))
+	}()
+
+	tx, err := client.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+
+	podAt := func(resourceVersion int64, phase string, generation int64) graph.Node {
+		return graph.Node{ID: "pod", Label: "Pod", Properties: map[string]interface{}{
+			"uid": "pod", "resourceVersion": resourceVersion, "phase": phase, "syncGeneration": generation,
+		}}
+	}
+
+	rejected, err := client.MergeNodes(ctx, tx, []graph.Node{podAt(10, "Running", 1)})
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+
+	// An older version is rejected but still takes the newer sync generation.
+	rejected, err = client.MergeNodes(ctx, tx, []graph.Node{podAt(9, "Pending", 2)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pod"}, rejected)
+
+	result, err := tx.Run(ctx, "MATCH (n:Pod {uid: 'pod'}) RETURN n.phase, n.resourceVersion, n.syncGeneration", nil)
+	require.NoError(t, err)
+	record, err := result.Single(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"Running", int64(10), int64(2)}, record.Values)
+
+	// The same or a newer version is written.
+	rejected, err = client.MergeNodes(ctx, tx, []graph.Node{podAt(11, "Succeeded", 3)})
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+
+	// Properties no longer written are removed.
+	_, err = client.MergeNodes(ctx, tx, []graph.Node{{ID: "pod", Label: "Pod", Properties: map[string]interface{}{
+		"uid": "pod", "resourceVersion": int64(12),
+	}}})
+	require.NoError(t, err)
+	result, err = tx.Run(ctx, "MATCH (n:Pod {uid: 'pod'}) RETURN n.phase, n.resourceVersion, n.syncGeneration", nil)
+	require.NoError(t, err)
+	record, err = result.Single(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, int64(12), int64(3)}, record.Values, "the sync generation is kept")
+
+	require.NoError(t, tx.Commit(ctx))
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	ctx := context.Background()
+
+	neo4jContainer, err := neo4j.Run(ctx, "neo4j:5", neo4j.WithAdminPassword("password"))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, neo4jContainer.Terminate(ctx))
+	}()
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Neo4jURI:      uri,
+		Neo4jUser:     "neo4j",
+		Neo4jPassword: "password",
+	}
+
+	client, err := NewClient(ctx, cfg)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, client.Close(ctx))
+	}()
+
+	wrongPassword := *cfg
+	wrongPassword.Neo4jPassword = "wrong"
+	require.Error(t, client.Reconnect(ctx, &wrongPassword))
+	require.NoError(t, client.VerifyConnectivity(ctx), "a failed reconnect should keep the current driver")
+
+	resized := *cfg
+	resized.Neo4jMaxPoolSize = 7
+	require.NoError(t, client.Reconnect(ctx, &resized))
+	assert.Equal(t, 7, client.MaxConnectionPoolSize())
+
+	tx, err := client.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+	_, err = tx.Run(ctx, "RETURN 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+}
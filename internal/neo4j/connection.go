@@ -0,0 +1,80 @@
+package neo4j
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// connection is a driver together with a count of the transactions open on it, so that
+// Reconnect can close a replaced driver once they have finished.
+type connection struct {
+	driver neo4j.DriverWithContext
+
+	mu   sync.Mutex
+	open int
+	// drained, once the connection is retired, is closed when no transaction is left open.
+	drained chan struct{}
+}
+
+func newConnection(driver neo4j.DriverWithContext) *connection {
+	return &connection{driver: driver}
+}
+
+// acquire records a transaction begun on the connection.
+func (c *connection) acquire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open++
+}
+
+// release records that a transaction begun on the connection has been closed.
+func (c *connection) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open--
+	if c.open == 0 && c.drained != nil {
+		close(c.drained)
+		c.drained = nil
+	}
+}
+
+// retire returns a channel that is closed once no transaction is open on the connection.
+// No transaction may be begun on it afterwards.
+func (c *connection) retire() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drained := make(chan struct{})
+	if c.open == 0 {
+		close(drained)
+	} else {
+		c.drained = drained
+	}
+	return drained
+}
+
+// openTransactions returns the number of transactions open on the connection.
+func (c *connection) openTransactions() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.open
+}
+
+// closeWhenDrained closes the driver once the transactions open on the connection have finished, or after
+// timeout at the latest, in which case the transactions still open are aborted.
+func (c *connection) closeWhenDrained(ctx context.Context, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-c.retire():
+	case <-timer.C:
+		slog.WarnContext(ctx, "closing previous Neo4j driver with transactions still open",
+			"transactions", c.openTransactions(), "timeout", timeout)
+	}
+	if err := c.driver.Close(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to close previous Neo4j driver", "err", err)
+	}
+}
@@ -0,0 +1,72 @@
+package neo4j
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+// closeRecorder is a driver that only records being closed.
+type closeRecorder struct {
+	neo4j.DriverWithContext
+	closed chan struct{}
+}
+
+func (d *closeRecorder) Close(context.Context) error {
+	close(d.closed)
+	return nil
+}
+
+func newCloseRecorder() *closeRecorder {
+	return &closeRecorder{closed: make(chan struct{})}
+}
+
+func TestConnection_ClosesOnceTransactionsDrain(t *testing.T) {
+	driver := newCloseRecorder()
+	conn := newConnection(driver)
+	conn.acquire()
+	conn.acquire()
+
+	go conn.closeWhenDrained(context.Background(), time.Minute)
+
+	conn.release()
+	select {
+	case <-driver.closed:
+		t.Fatal("driver closed while a transaction is still open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	conn.release()
+	select {
+	case <-driver.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("driver not closed once every transaction was closed")
+	}
+}
+
+func TestConnection_ClosesAfterTimeout(t *testing.T) {
+	driver := newCloseRecorder()
+	conn := newConnection(driver)
+	conn.acquire()
+
+	go conn.closeWhenDrained(context.Background(), 10*time.Millisecond)
+
+	select {
+	case <-driver.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("driver not closed after the timeout")
+	}
+	assert.Equal(t, 1, conn.openTransactions())
+}
+
+func TestConnection_RetireWithoutTransactions(t *testing.T) {
+	conn := newConnection(newCloseRecorder())
+
+	select {
+	case <-conn.retire():
+	default:
+		t.Fatal("an idle connection is not drained")
+	}
+}
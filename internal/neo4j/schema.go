@@ -0,0 +1,109 @@
+package neo4j
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// schemaObject is a constraint or index that kube-kg relies on.
+type schemaObject struct {
+	name       string
+	constraint bool
+	statement  string
+}
+
+// requiredSchema lists the constraints and indexes created on startup. The uid
+// constraint also provides the index used by every MERGE and MATCH on uid.
+func requiredSchema() []schemaObject {
+	return []schemaObject{
+		{
+			name:       "kubernetes_resource_uid",
+			constraint: true,
+			statement:  "CREATE CONSTRAINT kubernetes_resource_uid IF NOT EXISTS FOR (n:KubernetesResource) REQUIRE n.uid IS UNIQUE",
+		},
+		{
+			name:      "kubernetes_resource_namespace",
+			statement: "CREATE INDEX kubernetes_resource_namespace IF NOT EXISTS FOR (n:KubernetesResource) ON (n.namespace)",
+		},
+		{
+			name:      "kubernetes_resource_name",
+			statement: "CREATE INDEX kubernetes_resource_name IF NOT EXISTS FOR (n:KubernetesResource) ON (n.name)",
+		},
+		{
+			name:      "kubernetes_resource_kind",
+			statement: "CREATE INDEX kubernetes_resource_kind IF NOT EXISTS FOR (n:KubernetesResource) ON (n.kind)",
+		},
+	}
+}
+
+// EnsureSchema creates any missing constraints and indexes. It is safe to call
+// repeatedly: existing schema objects are left untouched.
+func (c *Client) EnsureSchema(ctx context.Context) error {
+	return c.ensureSchema(ctx, c.currentDriver())
+}
+
+// ensureSchema creates the missing constraints and indexes through driver, which need not be the current one.
+func (c *Client) ensureSchema(ctx context.Context, driver neo4j.DriverWithContext) (err error) {
+	ctx, end := c.startOperation(ctx, "EnsureSchema", "ensure_schema")
+	defer end(&err)
+
+	session := driver.NewSession(ctx, neo4j.SessionConfig{})
+	defer func() {
+		if err := session.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to close session", "err", err)
+		}
+	}()
+
+	tx := sessionRunner{session}
+	existing, err := c.existingSchemaNames(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var created []string
+	for _, object := range requiredSchema() {
+		if existing[object.name] {
+			continue
+		}
+		if object.constraint {
+			// Nodes written before the shared label existed must carry it before
+			// the constraint is created, otherwise MERGE would duplicate them.
+			if _, err := c.run(ctx, tx, "ensure_schema",
+				"MATCH (n) WHERE n.uid IS NOT NULL AND NOT n:KubernetesResource SET n:KubernetesResource", nil); err != nil {
+				return fmt.Errorf("failed to label existing nodes: %w", err)
+			}
+		}
+		if _, err := c.run(ctx, tx, "ensure_schema", object.statement, nil); err != nil {
+			return fmt.Errorf("failed to create %s: %w", object.name, err)
+		}
+		created = append(created, object.name)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("schema.created", created))
+	if len(created) > 0 {
+		slog.InfoContext(ctx, "created Neo4j schema objects", "objects", created)
+	}
+	return nil
+}
+
+// existingSchemaNames returns the names of all constraints and indexes in the database.
+func (c *Client) existingSchemaNames(ctx context.Context, tx runner) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, query := range []string{"SHOW CONSTRAINTS YIELD name", "SHOW INDEXES YIELD name"} {
+		records, err := c.run(ctx, tx, "ensure_schema", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		for _, record := range records {
+			if name, ok := record.Values[0].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+	return names, nil
+}
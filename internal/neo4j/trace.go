@@ -0,0 +1,113 @@
+package neo4j
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runner is implemented by both explicit and managed transactions.
+type runner interface {
+	Run(ctx context.Context, cypher string, params map[string]any) (neo4j.ResultWithContext, error)
+}
+
+// sessionRunner runs queries in the auto-commit transactions of a session.
+type sessionRunner struct {
+	session neo4j.SessionWithContext
+}
+
+func (r sessionRunner) Run(ctx context.Context, cypher string, params map[string]any) (neo4j.ResultWithContext, error) {
+	return r.session.Run(ctx, cypher, params)
+}
+
+// startOperation starts the span of an operation, named after the client method. The returned function
+// is meant to be deferred with a pointer to the operation's error: it records the error on the span,
+// ends it and records the operation's latency.
+func (c *Client) startOperation(ctx context.Context, name, operation string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNeo4j, semconv.DBOperation(operation)))
+	return ctx, func(err *error) {
+		endSpan(span, *err)
+		c.observe(ctx, operation, start, err)
+	}
+}
+
+// run runs a query in a span of its own and returns all of its records. The span records the
+// Cypher statement, which takes every value as a parameter, the database that ran it and the
+// counters of its summary.
+func (c *Client) run(ctx context.Context, tx runner, operation, query string,
+	params map[string]any) (_ []*neo4j.Record, err error) {
+	ctx, span := c.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNeo4j,
+			semconv.DBOperation(operation),
+			semconv.DBStatement(strings.TrimSpace(query)),
+		))
+	defer func() { endSpan(span, err) }()
+
+	result, err := tx.Run(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	records, err := result.Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := result.Consume(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(summaryAttributes(summary)...)
+	return records, nil
+}
+
+// single is like run for a query that returns exactly one record.
+func (c *Client) single(ctx context.Context, tx runner, operation, query string,
+	params map[string]any) (*neo4j.Record, error) {
+	records, err := c.run(ctx, tx, operation, query, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) != 1 {
+		return nil, fmt.Errorf("expected a single record, got %d", len(records))
+	}
+	return records[0], nil
+}
+
+// summaryAttributes describes the database a query ran in and the changes it made.
+func summaryAttributes(summary neo4j.ResultSummary) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if database := summary.Database(); database != nil {
+		attrs = append(attrs, semconv.DBName(database.Name()))
+	}
+	counters := summary.Counters()
+	return append(attrs,
+		attribute.Int("db.neo4j.nodes_created", counters.NodesCreated()),
+		attribute.Int("db.neo4j.nodes_deleted", counters.NodesDeleted()),
+		attribute.Int("db.neo4j.relationships_created", counters.RelationshipsCreated()),
+		attribute.Int("db.neo4j.relationships_deleted", counters.RelationshipsDeleted()),
+		attribute.Int("db.neo4j.properties_set", counters.PropertiesSet()),
+		attribute.Int("db.neo4j.labels_added", counters.LabelsAdded()),
+		attribute.Int("db.neo4j.indexes_added", counters.IndexesAdded()),
+		attribute.Int("db.neo4j.constraints_added", counters.ConstraintsAdded()),
+	)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
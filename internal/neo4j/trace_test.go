@@ -0,0 +1,133 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type runnerFunc func(ctx context.Context, cypher string, params map[string]any) (neo4j.ResultWithContext, error)
+
+func (f runnerFunc) Run(ctx context.Context, cypher string, params map[string]any) (neo4j.ResultWithContext, error) {
+	return f(ctx, cypher, params)
+}
+
+// fakeResult implements the parts of a result that run reads.
+type fakeResult struct {
+	neo4j.ResultWithContext
+	records []*neo4j.Record
+	summary neo4j.ResultSummary
+}
+
+func (r fakeResult) Collect(context.Context) ([]*neo4j.Record, error) { return r.records, nil }
+
+func (r fakeResult) Consume(context.Context) (neo4j.ResultSummary, error) { return r.summary, nil }
+
+type fakeSummary struct {
+	neo4j.ResultSummary
+	database string
+	counters fakeCounters
+}
+
+func (s fakeSummary) Database() neo4j.DatabaseInfo { return fakeDatabase{name: s.database} }
+
+func (s fakeSummary) Counters() neo4j.Counters { return s.counters }
+
+type fakeDatabase struct {
+	neo4j.DatabaseInfo
+	name string
+}
+
+func (d fakeDatabase) Name() string { return d.name }
+
+type fakeCounters struct {
+	neo4j.Counters
+	nodesCreated  int
+	propertiesSet int
+}
+
+func (c fakeCounters) NodesCreated() int         { return c.nodesCreated }
+func (c fakeCounters) NodesDeleted() int         { return 0 }
+func (c fakeCounters) RelationshipsCreated() int { return 0 }
+func (c fakeCounters) RelationshipsDeleted() int { return 0 }
+func (c fakeCounters) PropertiesSet() int        { return c.propertiesSet }
+func (c fakeCounters) LabelsAdded() int          { return 0 }
+func (c fakeCounters) IndexesAdded() int         { return 0 }
+func (c fakeCounters) ConstraintsAdded() int     { return 0 }
+
+func newTracedClient(t *testing.T) (*Client, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdkTrace.NewTracerProvider(sdkTrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	queryDuration, err := noop.NewMeterProvider().Meter("test").Float64Histogram("neo4j.query.duration")
+	require.NoError(t, err)
+	return &Client{tracer: provider.Tracer("test"), queryDuration: queryDuration}, recorder
+}
+
+func spanAttributes(span sdkTrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestClient_RunRecordsQuerySpan(t *testing.T) {
+	client, recorder := newTracedClient(t)
+	tx := runnerFunc(func(context.Context, string, map[string]any) (neo4j.ResultWithContext, error) {
+		return fakeResult{
+			records: []*neo4j.Record{{Values: []any{int64(1)}}},
+			summary: fakeSummary{database: "neo4j", counters: fakeCounters{nodesCreated: 2, propertiesSet: 6}},
+		}, nil
+	})
+
+	ctx, end := client.startOperation(context.Background(), "MergeNodes", "merge_nodes")
+	_, err := client.run(ctx, tx, "merge_nodes", "\n\tUNWIND $rows AS row\n\tMERGE (n {uid: row.uid})\n\t", nil)
+	end(&err)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	query, operation := spans[0], spans[1]
+	assert.Equal(t, "MergeNodes", operation.Name())
+	assert.Equal(t, operation.SpanContext().SpanID(), query.Parent().SpanID())
+
+	attrs := spanAttributes(query)
+	assert.Equal(t, "neo4j", attrs["db.system"].AsString())
+	assert.Equal(t, "neo4j", attrs["db.name"].AsString())
+	assert.Equal(t, "merge_nodes", attrs["db.operation"].AsString())
+	assert.Equal(t, "UNWIND $rows AS row\n\tMERGE (n {uid: row.uid})", attrs["db.statement"].AsString())
+	assert.Equal(t, int64(2), attrs["db.neo4j.nodes_created"].AsInt64())
+	assert.Equal(t, int64(6), attrs["db.neo4j.properties_set"].AsInt64())
+	assert.Equal(t, codes.Unset, query.Status().Code)
+}
+
+func TestClient_RunRecordsErrors(t *testing.T) {
+	client, recorder := newTracedClient(t)
+	tx := runnerFunc(func(context.Context, string, map[string]any) (neo4j.ResultWithContext, error) {
+		return nil, errors.New("connection reset")
+	})
+
+	ctx, end := client.startOperation(context.Background(), "DeleteNodes", "delete_nodes")
+	_, err := client.run(ctx, tx, "delete_nodes", "MATCH (n) DETACH DELETE n", nil)
+	end(&err)
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	for _, span := range spans {
+		assert.Equal(t, codes.Error, span.Status().Code, span.Name())
+		require.Len(t, span.Events(), 1, span.Name())
+		assert.Equal(t, "exception", span.Events()[0].Name)
+	}
+}
@@ -0,0 +1,49 @@
+package observability
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Log formats that can be selected.
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// NewLogHandler returns a slog handler writing records to w as JSON or, for LogFormatText, as text,
+// dropping those below level. Records logged with a context that carries a span get its trace_id and
+// span_id, so that they can be joined to the trace.
+func NewLogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatText {
+		return traceHandler{slog.NewTextHandler(w, opts)}
+	}
+	return traceHandler{slog.NewJSONHandler(w, opts)}
+}
+
+// traceHandler adds the trace and span IDs of the context's span to each record.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,72 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewLogHandler_AddsTraceContext(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(&buf, LogFormatJSON, slog.LevelInfo)).With("component", "test")
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	// Act
+	logger.InfoContext(ctx, "with span")
+	logger.InfoContext(context.Background(), "without span")
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+	var withSpan, withoutSpan map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &withSpan); err != nil {
+		t.Fatalf("failed to decode log line: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &withoutSpan); err != nil {
+		t.Fatalf("failed to decode log line: %v", err)
+	}
+	if withSpan["trace_id"] != "0102030405060708090a0b0c0d0e0f10" || withSpan["span_id"] != "0102030405060708" {
+		t.Errorf("expected the span's ids, got trace_id=%v span_id=%v", withSpan["trace_id"], withSpan["span_id"])
+	}
+	if withSpan["component"] != "test" {
+		t.Errorf("expected attributes added with With to be kept, got %v", withSpan["component"])
+	}
+	if _, ok := withoutSpan["trace_id"]; ok {
+		t.Error("expected no trace_id without a span")
+	}
+}
+
+func TestNewLogHandler_FormatAndLevel(t *testing.T) {
+	// Arrange
+	var buf bytes.Buffer
+	var level slog.LevelVar
+	level.Set(slog.LevelWarn)
+	logger := slog.New(NewLogHandler(&buf, LogFormatText, &level))
+
+	// Act
+	logger.Info("dropped")
+	level.Set(slog.LevelDebug)
+	logger.Debug("kept")
+
+	// Assert
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Errorf("expected the info record to be dropped at warn level, got %s", out)
+	}
+	if !strings.Contains(out, "level=DEBUG msg=kept") {
+		t.Errorf("expected a text record once the level was lowered, got %s", out)
+	}
+}
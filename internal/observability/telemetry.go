@@ -2,55 +2,294 @@ package observability
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
 
+	"kube-kg/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	sdkMetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// Exporters that can be selected for traces and metrics.
+const (
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterStdout   = "stdout"
+	ExporterNone     = "none"
 )
 
-// InitTracerProvider initializes and returns a new OpenTelemetry TracerProvider.
-func InitTracerProvider(ctx context.Context, endpoint string) (*sdkTrace.TracerProvider, error) {
-	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure(), otlptracegrpc.WithEndpoint(endpoint))
+// InitTracerProvider initializes and returns a new OpenTelemetry TracerProvider, exporting spans with
+// the exporter selected in cfg. With no exporter, spans are still created, so that logs can be correlated
+// with them, but they are dropped.
+func InitTracerProvider(ctx context.Context, cfg *config.Config) (*sdkTrace.TracerProvider, error) {
+	res, err := newResource(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String("kube-kg"),
-		),
-	)
+	opts := []sdkTrace.TracerProviderOption{
+		sdkTrace.WithResource(res),
+		sdkTrace.WithSampler(newSampler(cfg.TraceSampler, cfg.TraceSamplerRatio)),
+	}
+	exporter, err := newSpanExporter(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.TraceExporter, err)
+	}
+	if exporter != nil {
+		opts = append(opts, sdkTrace.WithBatcher(exporter))
 	}
 
-	tp := sdkTrace.NewTracerProvider(
-		sdkTrace.WithBatcher(exporter),
-		sdkTrace.WithResource(res),
-	)
+	tp := sdkTrace.NewTracerProvider(opts...)
 
 	otel.SetTracerProvider(tp)
+	// Outgoing requests carry the W3C trace context, so that the servers' spans join our traces.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
 	return tp, nil
 }
 
-// InitMeterProvider initializes and returns a new OpenTelemetry MeterProvider.
-func InitMeterProvider(ctx context.Context, endpoint string) (metric.MeterProvider, error) {
-	exporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithInsecure(), otlpmetricgrpc.WithEndpoint(endpoint))
+// InitMeterProvider initializes and returns a new OpenTelemetry MeterProvider. Metrics are pushed
+// with the exporter selected in cfg and can always be scraped by Prometheus through the returned handler.
+func InitMeterProvider(ctx context.Context, cfg *config.Config) (*sdkMetric.MeterProvider, http.Handler, error) {
+	// A registry of our own keeps the handler free of metrics registered globally by dependencies.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	promExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to create Prometheus metric exporter: %w", err)
 	}
 
-	mp := sdkMetric.NewMeterProvider(sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exporter)))
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []sdkMetric.Option{
+		sdkMetric.WithReader(promExporter),
+		sdkMetric.WithResource(res),
+	}
+	exporter, err := newMetricExporter(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create %s metric exporter: %w", cfg.MetricExporter, err)
+	}
+	if exporter != nil {
+		opts = append(opts, sdkMetric.WithReader(sdkMetric.NewPeriodicReader(exporter)))
+	}
+
+	mp := sdkMetric.NewMeterProvider(opts...)
 	otel.SetMeterProvider(mp)
 
-	return mp, nil
+	return mp, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), nil
+}
+
+// newSpanExporter returns the exporter selected by cfg.TraceExporter, or nil for none.
+func newSpanExporter(ctx context.Context, cfg *config.Config) (sdkTrace.SpanExporter, error) {
+	conn, err := newOTLPConnection(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.TraceExporter {
+	case ExporterOTLPGRPC:
+		return otlptracegrpc.New(ctx, buildOTLPOptions(conn, otlpOptions[otlptracegrpc.Option]{
+			endpointURL: otlptracegrpc.WithEndpointURL,
+			endpoint:    otlptracegrpc.WithEndpoint,
+			insecure:    otlptracegrpc.WithInsecure,
+			tls: func(c *tls.Config) otlptracegrpc.Option {
+				return otlptracegrpc.WithTLSCredentials(credentials.NewTLS(c))
+			},
+		})...)
+	case ExporterOTLPHTTP:
+		return otlptracehttp.New(ctx, buildOTLPOptions(conn, otlpOptions[otlptracehttp.Option]{
+			endpointURL: otlptracehttp.WithEndpointURL,
+			endpoint:    otlptracehttp.WithEndpoint,
+			insecure:    otlptracehttp.WithInsecure,
+			tls:         otlptracehttp.WithTLSClientConfig,
+		})...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.TraceExporter)
+	}
+}
+
+// newMetricExporter returns the exporter selected by cfg.MetricExporter, or nil for none.
+func newMetricExporter(ctx context.Context, cfg *config.Config) (sdkMetric.Exporter, error) {
+	conn, err := newOTLPConnection(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.MetricExporter {
+	case ExporterOTLPGRPC:
+		return otlpmetricgrpc.New(ctx, buildOTLPOptions(conn, otlpOptions[otlpmetricgrpc.Option]{
+			endpointURL: otlpmetricgrpc.WithEndpointURL,
+			endpoint:    otlpmetricgrpc.WithEndpoint,
+			insecure:    otlpmetricgrpc.WithInsecure,
+			tls: func(c *tls.Config) otlpmetricgrpc.Option {
+				return otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(c))
+			},
+		})...)
+	case ExporterOTLPHTTP:
+		return otlpmetrichttp.New(ctx, buildOTLPOptions(conn, otlpOptions[otlpmetrichttp.Option]{
+			endpointURL: otlpmetrichttp.WithEndpointURL,
+			endpoint:    otlpmetrichttp.WithEndpoint,
+			insecure:    otlpmetrichttp.WithInsecure,
+			tls:         otlpmetrichttp.WithTLSClientConfig,
+		})...)
+	case ExporterStdout:
+		return stdoutmetric.New(stdoutmetric.WithWriter(os.Stdout))
+	case ExporterNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %q", cfg.MetricExporter)
+	}
+}
+
+// otlpConnection holds how the OTLP exporters of every signal and transport reach the collector.
+type otlpConnection struct {
+	endpoint string
+	// isURL reports whether endpoint is a URL rather than host:port.
+	isURL bool
+	// insecure sends OTLP in plain text.
+	insecure  bool
+	tlsConfig *tls.Config
+}
+
+// newOTLPConnection reads the OTLP connection settings from cfg. Plain text is only used when asked for
+// and neither an https endpoint nor a certificate calls for TLS.
+func newOTLPConnection(cfg *config.Config) (otlpConnection, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return otlpConnection{}, err
+	}
+	endpoint := cfg.OtelExporterEndpoint
+	https := strings.HasPrefix(strings.ToLower(endpoint), "https://")
+	return otlpConnection{
+		endpoint:  endpoint,
+		isURL:     strings.Contains(endpoint, "://"),
+		insecure:  cfg.OtelExporterInsecure && !https && tlsConfig == nil,
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+// otlpOptions holds the option constructors of one OTLP exporter package.
+type otlpOptions[O any] struct {
+	endpointURL func(string) O
+	endpoint    func(string) O
+	insecure    func() O
+	tls         func(*tls.Config) O
+}
+
+// buildOTLPOptions returns the options connecting an OTLP exporter as conn describes.
+func buildOTLPOptions[O any](conn otlpConnection, opts otlpOptions[O]) []O {
+	var options []O
+	switch {
+	case conn.isURL:
+		options = append(options, opts.endpointURL(conn.endpoint))
+	case conn.endpoint != "":
+		options = append(options, opts.endpoint(conn.endpoint))
+	}
+	switch {
+	case conn.insecure:
+		options = append(options, opts.insecure())
+	case conn.tlsConfig != nil:
+		options = append(options, opts.tls(conn.tlsConfig))
+	}
+	return options
+}
+
+// newTLSConfig builds the TLS configuration for OTLP from the configured certificates. It returns nil
+// when none is configured, leaving the exporter to verify the collector against the system roots.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.OtelExporterCertificate == "" && cfg.OtelExporterClientCertificate == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.OtelExporterCertificate != "" {
+		pem, err := os.ReadFile(cfg.OtelExporterCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OTLP certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, errors.New("failed to parse OTLP certificate")
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.OtelExporterClientCertificate != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.OtelExporterClientCertificate, cfg.OtelExporterClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load OTLP client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// newSampler returns the sampler named as in OTEL_TRACES_SAMPLER. Unknown names sample every trace
+// whose parent is sampled, the SDK's default.
+func newSampler(name string, ratio float64) sdkTrace.Sampler {
+	switch name {
+	case "always_on":
+		return sdkTrace.AlwaysSample()
+	case "always_off":
+		return sdkTrace.NeverSample()
+	case "traceidratio":
+		return sdkTrace.TraceIDRatioBased(ratio)
+	case "parentbased_always_off":
+		return sdkTrace.ParentBased(sdkTrace.NeverSample())
+	case "parentbased_traceidratio":
+		return sdkTrace.ParentBased(sdkTrace.TraceIDRatioBased(ratio))
+	default:
+		return sdkTrace.ParentBased(sdkTrace.AlwaysSample())
+	}
+}
+
+// newResource describes the service. Attributes in OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME
+// take precedence over those from cfg.
+func newResource(ctx context.Context, cfg *config.Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{semconv.ServiceNameKey.String("kube-kg")}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(cfg.ServiceVersion))
+	}
+	if cfg.ClusterName != "" {
+		attrs = append(attrs, semconv.K8SClusterNameKey.String(cfg.ClusterName))
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+	return res, nil
 }
 
 // GetTracer returns a new tracer instance.
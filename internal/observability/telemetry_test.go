@@ -2,12 +2,44 @@ package observability
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"kube-kg/internal/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+func testConfig() *config.Config {
+	return &config.Config{
+		TraceExporter:        ExporterOTLPGRPC,
+		MetricExporter:       ExporterOTLPGRPC,
+		OtelExporterEndpoint: "localhost:4317",
+		OtelExporterInsecure: true,
+		TraceSampler:         "parentbased_always_on",
+		TraceSamplerRatio:    1,
+	}
+}
+
 func TestGetTracer(t *testing.T) {
 	// Arrange
-	_, err := InitTracerProvider(context.Background(), "localhost:4317")
+	_, err := InitTracerProvider(context.Background(), testConfig())
 	if err != nil {
 		t.Fatalf("failed to initialize tracer provider: %v", err)
 	}
@@ -23,7 +55,7 @@ func TestGetTracer(t *testing.T) {
 
 func TestGetMeter(t *testing.T) {
 	// Arrange
-	_, err := InitMeterProvider(context.Background(), "localhost:4317")
+	_, _, err := InitMeterProvider(context.Background(), testConfig())
 	if err != nil {
 		t.Fatalf("failed to initialize meter provider: %v", err)
 	}
@@ -36,3 +68,205 @@ func TestGetMeter(t *testing.T) {
 		t.Error("expected a meter instance, got nil")
 	}
 }
+
+func TestInitMeterProvider_ServesPrometheusMetrics(t *testing.T) {
+	// Arrange
+	provider, handler, err := InitMeterProvider(context.Background(), testConfig())
+	if err != nil {
+		t.Fatalf("failed to initialize meter provider: %v", err)
+	}
+	counter, err := provider.Meter("test").Int64Counter("test.requests")
+	if err != nil {
+		t.Fatalf("failed to create counter: %v", err)
+	}
+	counter.Add(context.Background(), 3)
+
+	// Act
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "test_requests_total") {
+		t.Errorf("expected the counter to be exported, got:\n%s", body)
+	}
+	if !strings.Contains(body, "go_goroutines") {
+		t.Errorf("expected runtime metrics to be exported, got:\n%s", body)
+	}
+}
+
+func TestInitProviders_Exporters(t *testing.T) {
+	for _, exporter := range []string{ExporterOTLPGRPC, ExporterOTLPHTTP, ExporterStdout, ExporterNone} {
+		t.Run(exporter, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.TraceExporter = exporter
+			cfg.MetricExporter = exporter
+			cfg.OtelExporterEndpoint = "http://collector.example.com:4318"
+
+			tp, err := InitTracerProvider(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("failed to initialize tracer provider: %v", err)
+			}
+			mp, handler, err := InitMeterProvider(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("failed to initialize meter provider: %v", err)
+			}
+			if handler == nil {
+				t.Error("expected a Prometheus handler whatever the exporter")
+			}
+			// Nothing listens at the endpoint, so do not wait for the final export.
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_ = tp.Shutdown(ctx)
+			_ = mp.Shutdown(ctx)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		cfg := testConfig()
+		cfg.TraceExporter = "zipkin"
+		if _, err := InitTracerProvider(context.Background(), cfg); err == nil {
+			t.Error("expected an error for an unknown exporter")
+		}
+	})
+}
+
+func TestInitTracerProvider_InvalidCertificate(t *testing.T) {
+	cfg := testConfig()
+	cfg.OtelExporterInsecure = false
+	cfg.OtelExporterCertificate = "/nonexistent/ca.pem"
+
+	if _, err := InitTracerProvider(context.Background(), cfg); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+}
+
+// writeCACertificate writes a self-signed CA certificate in PEM and returns its path.
+func writeCACertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	return path
+}
+
+func TestBuildOTLPOptions(t *testing.T) {
+	options := otlpOptions[string]{
+		endpointURL: func(url string) string { return "url " + url },
+		endpoint:    func(endpoint string) string { return "endpoint " + endpoint },
+		insecure:    func() string { return "insecure" },
+		tls:         func(*tls.Config) string { return "tls" },
+	}
+	certificate := writeCACertificate(t)
+
+	tests := []struct {
+		name        string
+		endpoint    string
+		insecure    bool
+		certificate string
+		want        []string
+	}{
+		{name: "default"},
+		{name: "plain text", endpoint: "collector:4317", insecure: true,
+			want: []string{"endpoint collector:4317", "insecure"}},
+		{name: "https endpoint", endpoint: "https://collector:4318", insecure: true,
+			want: []string{"url https://collector:4318"}},
+		{name: "certificate", endpoint: "collector:4317", insecure: true, certificate: certificate,
+			want: []string{"endpoint collector:4317", "tls"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			cfg := testConfig()
+			cfg.OtelExporterEndpoint = tt.endpoint
+			cfg.OtelExporterInsecure = tt.insecure
+			cfg.OtelExporterCertificate = tt.certificate
+
+			// Act
+			conn, err := newOTLPConnection(cfg)
+			if err != nil {
+				t.Fatalf("failed to read OTLP connection: %v", err)
+			}
+			got := buildOTLPOptions(conn, options)
+
+			// Assert
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	sampled := func(sampler sdkTrace.Sampler) bool {
+		result := sampler.ShouldSample(sdkTrace.SamplingParameters{
+			ParentContext: context.Background(),
+			TraceID:       trace.TraceID{8: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff},
+		})
+		return result.Decision == sdkTrace.RecordAndSample
+	}
+
+	tests := []struct {
+		name  string
+		ratio float64
+		want  bool
+	}{
+		{"always_on", 0, true},
+		{"always_off", 1, false},
+		{"traceidratio", 0.000001, false},
+		{"traceidratio", 1, true},
+		{"parentbased_always_on", 0, true},
+		{"parentbased_always_off", 0, false},
+		{"parentbased_traceidratio", 1, true},
+		{"unknown", 0, true},
+	}
+	for _, tt := range tests {
+		if got := sampled(newSampler(tt.name, tt.ratio)); got != tt.want {
+			t.Errorf("newSampler(%q, %v) sampled a root span: got %v, want %v", tt.name, tt.ratio, got, tt.want)
+		}
+	}
+}
+
+func TestNewResource(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging,service.version=from-env")
+	cfg := testConfig()
+	cfg.ClusterName = "prod-eu"
+	cfg.ServiceVersion = "1.2.3"
+
+	res, err := newResource(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to create resource: %v", err)
+	}
+
+	want := map[attribute.Key]string{
+		semconv.ServiceNameKey:    "kube-kg",
+		semconv.K8SClusterNameKey: "prod-eu",
+		semconv.ServiceVersionKey: "from-env",
+		"deployment.environment":  "staging",
+	}
+	for key, value := range want {
+		got, ok := res.Set().Value(key)
+		if !ok || got.AsString() != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, got.AsString())
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package processor
+
+import "kube-kg/internal/kubeview"
+
+// coalescer keeps the latest pending event for each resource UID, in the order the
+// resources were first seen.
+type coalescer struct {
+	order   []string
+	pending map[string]kubeview.Event
+	// coalesced counts the events dropped since the last take.
+	coalesced int
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{pending: make(map[string]kubeview.Event)}
+}
+
+// add records event, superseding any pending event for the same resource. A resource
+// added and deleted before being written is dropped altogether.
+func (c *coalescer) add(event kubeview.Event) {
+	uid := event.Object.Metadata.UID
+	previous, ok := c.pending[uid]
+	if !ok {
+		c.order = append(c.order, uid)
+		c.pending[uid] = event
+		return
+	}
+
+	switch {
+	case previous.Type == kubeview.EventTypeAdd && event.Type == kubeview.EventTypeDelete:
+		delete(c.pending, uid)
+		c.coalesced += 2
+		return
+	case previous.Type == kubeview.EventTypeAdd && event.Type == kubeview.EventTypeUpdate:
+		// The resource has still not been written, so the update stands in for the add.
+		event.Type = kubeview.EventTypeAdd
+	}
+	c.pending[uid] = event
+	c.coalesced++
+}
+
+// take returns the pending events and the number of events they replaced, and resets the coalescer.
+func (c *coalescer) take() ([]kubeview.Event, int) {
+	events := make([]kubeview.Event, 0, len(c.pending))
+	for _, uid := range c.order {
+		// A resource dropped and seen again appears twice in order.
+		if event, ok := c.pending[uid]; ok {
+			events = append(events, event)
+			delete(c.pending, uid)
+		}
+	}
+	coalesced := c.coalesced
+	c.order = nil
+	c.pending = make(map[string]kubeview.Event)
+	c.coalesced = 0
+	return events, coalesced
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"testing"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func typedEvent(eventType, uid, resourceVersion string) kubeview.Event {
+	event := eventFor(uid, resourceVersion)
+	event.Type = eventType
+	return event
+}
+
+func TestCoalescer(t *testing.T) {
+	tests := []struct {
+		name          string
+		events        []kubeview.Event
+		want          []kubeview.Event
+		wantCoalesced int
+	}{
+		{
+			name: "keeps the latest update per resource",
+			events: []kubeview.Event{
+				typedEvent(kubeview.EventTypeUpdate, "a", "1"),
+				typedEvent(kubeview.EventTypeUpdate, "b", "1"),
+				typedEvent(kubeview.EventTypeUpdate, "a", "2"),
+			},
+			want: []kubeview.Event{
+				typedEvent(kubeview.EventTypeUpdate, "a", "2"),
+				typedEvent(kubeview.EventTypeUpdate, "b", "1"),
+			},
+			wantCoalesced: 1,
+		},
+		{
+			name: "add followed by delete cancels out",
+			events: []kubeview.Event{
+				typedEvent(kubeview.EventTypeAdd, "a", "1"),
+				typedEvent(kubeview.EventTypeUpdate, "a", "2"),
+				typedEvent(kubeview.EventTypeDelete, "a", "3"),
+			},
+			want:          []kubeview.Event{},
+			wantCoalesced: 3,
+		},
+		{
+			name: "update followed by delete is a delete",
+			events: []kubeview.Event{
+				typedEvent(kubeview.EventTypeUpdate, "a", "1"),
+				typedEvent(kubeview.EventTypeDelete, "a", "2"),
+			},
+			want:          []kubeview.Event{typedEvent(kubeview.EventTypeDelete, "a", "2")},
+			wantCoalesced: 1,
+		},
+		{
+			name: "delete followed by add is an add",
+			events: []kubeview.Event{
+				typedEvent(kubeview.EventTypeDelete, "a", "1"),
+				typedEvent(kubeview.EventTypeAdd, "a", "2"),
+			},
+			want:          []kubeview.Event{typedEvent(kubeview.EventTypeAdd, "a", "2")},
+			wantCoalesced: 1,
+		},
+		{
+			name: "resource added again after cancelling out appears once",
+			events: []kubeview.Event{
+				typedEvent(kubeview.EventTypeAdd, "a", "1"),
+				typedEvent(kubeview.EventTypeDelete, "a", "2"),
+				typedEvent(kubeview.EventTypeAdd, "a", "3"),
+			},
+			want:          []kubeview.Event{typedEvent(kubeview.EventTypeAdd, "a", "3")},
+			wantCoalesced: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCoalescer()
+			for _, event := range tt.events {
+				c.add(event)
+			}
+
+			events, coalesced := c.take()
+			assert.Equal(t, tt.want, events)
+			assert.Equal(t, tt.wantCoalesced, coalesced)
+
+			events, coalesced = c.take()
+			assert.Empty(t, events)
+			assert.Zero(t, coalesced)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package processor
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"kube-kg/internal/graph"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ResyncMode selects what a periodic resync writes.
+type ResyncMode string
+
+const (
+	// ResyncFull rewrites every namespace and prunes what it no longer contains.
+	ResyncFull ResyncMode = "full"
+	// ResyncIncremental only rewrites the namespaces whose graph has drifted from KubeView.
+	ResyncIncremental ResyncMode = "incremental"
+)
+
+// Drift counts the differences between a KubeView snapshot of a namespace and its graph.
+type Drift struct {
+	// Missing resources exist in KubeView but have no node.
+	Missing int `json:"missing"`
+	// Extra nodes have no resource in KubeView.
+	Extra int `json:"extra"`
+	// Changed nodes have properties that differ from their resource.
+	Changed int `json:"changed"`
+}
+
+// Total returns the number of resources that have drifted.
+func (d Drift) Total() int {
+	return d.Missing + d.Extra + d.Changed
+}
+
+// diffNamespace compares the nodes built from a snapshot with the properties stored in the graph.
+// A node whose stored resourceVersion is newer than the snapshot's is not counted as changed, as
+// an event has already written a later state.
+func diffNamespace(nodes []graph.Node, stored map[string]map[string]any) Drift {
+	var drift Drift
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		seen[node.ID] = true
+		props, ok := stored[node.ID]
+		if !ok {
+			drift.Missing++
+			continue
+		}
+		if newerInGraph(node.Properties, props) {
+			continue
+		}
+		if !sameProperties(node.Properties, props) {
+			drift.Changed++
+		}
+	}
+	for uid := range stored {
+		if !seen[uid] {
+			drift.Extra++
+		}
+	}
+	return drift
+}
+
+func newerInGraph(snapshot, stored map[string]any) bool {
+	want, okWant := snapshot["resourceVersion"].(int64)
+	have, okHave := stored["resourceVersion"].(int64)
+	return okWant && okHave && have > want
+}
+
+// sameProperties reports whether two property sets are equal, ignoring the sync generation.
+func sameProperties(a, b map[string]any) bool {
+	count := func(props map[string]any) int {
+		if _, ok := props[generationProperty]; ok {
+			return len(props) - 1
+		}
+		return len(props)
+	}
+	if count(a) != count(b) {
+		return false
+	}
+	for key, value := range a {
+		if key == generationProperty {
+			continue
+		}
+		other, ok := b[key]
+		if !ok || !sameValue(value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue compares property values. Times are compared as instants, as Neo4j may return them
+// in a different location than they were written in.
+func sameValue(a, b any) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// recordDrift reports the drift of a namespace through metrics, the current span and the log.
+func (p *Processor) recordDrift(ctx context.Context, namespace string, drift Drift) {
+	for kind, count := range map[string]int{"missing": drift.Missing, "extra": drift.Extra, "changed": drift.Changed} {
+		if count > 0 {
+			p.syncMetrics.drift.Add(ctx, int64(count), metric.WithAttributes(attribute.String("type", kind)))
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("drift.missing", drift.Missing),
+		attribute.Int("drift.extra", drift.Extra),
+		attribute.Int("drift.changed", drift.Changed),
+	)
+	if drift.Total() > 0 {
+		slog.WarnContext(ctx, "graph drifted from kubeview", "namespace", namespace,
+			"missing", drift.Missing, "extra", drift.Extra, "changed", drift.Changed)
+	}
+}
+
+// StartResyncScheduler starts a goroutine that resyncs the synchronized namespaces at the interval set
+// with WithPeriodicResync. It does nothing if no interval is set.
+func (p *Processor) StartResyncScheduler(ctx context.Context) {
+	if p.resyncInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(p.resyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.periodicResync(ctx)
+			}
+		}
+	}()
+}
+
+// periodicResync lists the namespaces again, so that new and newly included ones are synchronized
+// too, and resyncs those the filter allows.
+func (p *Processor) periodicResync(ctx context.Context) {
+	if p.State() != StateLive {
+		// The initial sync is still running; it writes everything anyway.
+		slog.DebugContext(ctx, "skipping periodic resync until the initial sync completes")
+		return
+	}
+
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "PeriodicResync", trace.WithAttributes(
+		attribute.String("mode", string(p.resyncMode)),
+	))
+	defer span.End()
+
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	namespaceResult, err := p.kubeClient.ListNamespaces(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "periodic resync failed to list namespaces", "err", err)
+		return
+	}
+	p.setSyncedNamespaces(namespaceResult.Namespaces)
+	namespaces := p.filter().filter(namespaceResult.Namespaces)
+
+	slog.InfoContext(ctx, "starting periodic resync", "mode", p.resyncMode, "namespaces", len(namespaces))
+	opts := syncOptions{detectDrift: true, onlyDrifted: p.resyncMode == ResyncIncremental, full: true}
+	if err := p.syncNamespaces(ctx, namespaces, opts); err != nil {
+		slog.ErrorContext(ctx, "periodic resync failed", "err", err)
+		return
+	}
+	if report := p.LastSyncReport(); report != nil && report.Drift != nil {
+		slog.InfoContext(ctx, "periodic resync completed", "drifted", report.Drift.Total())
+	}
+}
@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"kube-kg/internal/graph"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffNamespace(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	node := func(uid string, version int64) graph.Node {
+		return graph.Node{ID: uid, Label: "Pod", Properties: map[string]any{
+			"uid": uid, "name": uid, "resourceVersion": version, "creationTimestamp": created,
+		}}
+	}
+	stored := func(uid string, version int64) map[string]any {
+		return map[string]any{
+			"uid": uid, "name": uid, "resourceVersion": version,
+			"creationTimestamp": created.In(time.FixedZone("CET", 3600)), generationProperty: int64(42),
+		}
+	}
+
+	nodes := []graph.Node{node("same", 1), node("missing", 1), node("changed", 2), node("newer-in-graph", 2)}
+	graphNodes := map[string]map[string]any{
+		"same":           stored("same", 1),
+		"changed":        stored("changed", 1),
+		"newer-in-graph": stored("newer-in-graph", 3),
+		"extra":          stored("extra", 1),
+	}
+
+	assert.Equal(t, Drift{Missing: 1, Extra: 1, Changed: 1}, diffNamespace(nodes, graphNodes))
+
+	// A label added outside the sync is a change even though the resourceVersion matches.
+	relabelled := stored("same", 1)
+	relabelled["label.app"] = "web"
+	assert.Equal(t, Drift{Changed: 1}, diffNamespace(nodes[:1], map[string]map[string]any{"same": relabelled}))
+}
@@ -0,0 +1,41 @@
+package processor
+
+import (
+	"path"
+	"slices"
+)
+
+// NamespaceFilter selects the namespaces kept in the graph with glob patterns as understood by path.Match.
+// The zero NamespaceFilter keeps every namespace.
+type NamespaceFilter struct {
+	// Include lists the namespaces to keep. Empty means every namespace not excluded.
+	Include []string
+	// Exclude lists the namespaces to leave out, even if included.
+	Exclude []string
+}
+
+// Allows reports whether the filter keeps the namespace. Cluster-scoped resources, which have no
+// namespace, are always kept.
+func (f NamespaceFilter) Allows(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	if matchesAny(f.Exclude, namespace) {
+		return false
+	}
+	return len(f.Include) == 0 || matchesAny(f.Include, namespace)
+}
+
+// filter returns the namespaces the filter keeps.
+func (f NamespaceFilter) filter(namespaces []string) []string {
+	return slices.DeleteFunc(slices.Clone(namespaces), func(namespace string) bool {
+		return !f.Allows(namespace)
+	})
+}
+
+func matchesAny(patterns []string, namespace string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, err := path.Match(pattern, namespace)
+		return err == nil && matched
+	})
+}
@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceFilter_Allows(t *testing.T) {
+	filter := NamespaceFilter{Include: []string{"shop", "web-*"}, Exclude: []string{"web-test"}}
+	tests := []struct {
+		namespace string
+		want      bool
+	}{
+		{namespace: "", want: true},
+		{namespace: "shop", want: true},
+		{namespace: "web-prod", want: true},
+		{namespace: "web-test", want: false},
+		{namespace: "kube-system", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			assert.Equal(t, tt.want, filter.Allows(tt.namespace))
+		})
+	}
+
+	assert.True(t, NamespaceFilter{}.Allows("kube-system"), "the zero filter should keep every namespace")
+	assert.False(t, NamespaceFilter{Exclude: []string{"kube-*"}}.Allows("kube-system"))
+	assert.Equal(t, []string{"shop", "web-prod"}, filter.filter([]string{"kube-system", "shop", "web-prod", "web-test"}))
+}
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"kube-kg/internal/kubeview"
+)
+
+// DefaultReplayBufferSize is the number of events buffered during an initial sync when none is configured.
+const DefaultReplayBufferSize = 10000
+
+// State is a stage in the lifecycle of the event processor.
+type State string
+
+const (
+	// StateSyncing means an initial sync is running. Events are buffered rather than applied,
+	// so that none can be overwritten by the snapshot being written.
+	StateSyncing State = "syncing"
+	// StateReplaying means the sync has finished and the buffered events are being replayed.
+	StateReplaying State = "replaying"
+	// StateLive means events are applied as they arrive.
+	StateLive State = "live"
+)
+
+// State returns the current lifecycle state of the processor.
+func (p *Processor) State() State {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	return p.state
+}
+
+// InitialSyncCompleted reports whether a full sync has settled every namespace: each one was synchronized
+// or failed with an error that retrying would not fix. Once set, it stays set.
+func (p *Processor) InitialSyncCompleted() bool {
+	return p.initialSynced.Load()
+}
+
+// QueuedEvents returns the number of events waiting to be applied by the event workers.
+func (p *Processor) QueuedEvents() int {
+	if p.events == nil {
+		return 0
+	}
+	return p.events.depth()
+}
+
+// CheckEventLoop returns an error if events have been waiting for longer than stallTimeout
+// without the event workers applying any.
+func (p *Processor) CheckEventLoop(stallTimeout time.Duration) error {
+	if p.events == nil {
+		return nil
+	}
+	if stalled := p.events.stalledFor(); stalled > stallTimeout {
+		return fmt.Errorf("event workers have made no progress for %s with %d events queued",
+			stalled.Round(time.Second), p.events.depth())
+	}
+	return nil
+}
+
+// BufferedEvents returns the number of events buffered for replay while a sync runs.
+func (p *Processor) BufferedEvents() int {
+	return int(p.bufferedEvents.Load())
+}
+
+// beginSync switches the processor to the syncing state. Every call must be paired with endSync.
+func (p *Processor) beginSync() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.activeSyncs++
+	p.state = StateSyncing
+}
+
+// endSync tells the event gate, once no sync is left running, that the buffer can be replayed.
+func (p *Processor) endSync() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	p.activeSyncs--
+	if p.activeSyncs > 0 {
+		return
+	}
+	select {
+	case p.syncDone <- struct{}{}:
+	default:
+	}
+}
+
+// transition moves the processor from one state to another, reporting false if it was not in
+// from or if a sync is running.
+func (p *Processor) transition(from, to State) bool {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+	if p.state != from || p.activeSyncs > 0 {
+		return false
+	}
+	p.state = to
+	return true
+}
+
+// gate forwards events from in to out while the processor is live. While it is syncing, events
+// are buffered instead, up to the replay buffer size; beyond that, in is no longer read until the
+// sync has finished. On shutdown, events already received are forwarded unless still buffered.
+func (p *Processor) gate(ctx context.Context, in <-chan kubeview.Event, out chan<- kubeview.Event) {
+	defer close(out)
+
+	var buffered []kubeview.Event
+	defer p.bufferedEvents.Store(0)
+	for {
+		p.bufferedEvents.Store(int64(len(buffered)))
+		events := in
+		if len(buffered) >= p.replayBufferSize {
+			events = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if len(buffered) > 0 {
+				slog.WarnContext(ctx, "discarding events buffered during sync", "events", len(buffered))
+			}
+			if p.State() != StateSyncing {
+				forwardBuffered(in, out)
+			}
+			return
+		case <-p.syncDone:
+			buffered = p.replay(ctx, buffered, out)
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			p.eventMetrics.recordReceived(ctx, event)
+			if p.State() == StateSyncing {
+				buffered = append(buffered, event)
+				if len(buffered) == p.replayBufferSize {
+					slog.WarnContext(ctx, "replay buffer full, pausing event stream until the sync finishes",
+						"events", len(buffered))
+				}
+				continue
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// replay forwards the buffered events that are newer than the synced snapshot, in the order
+// received, and switches the processor to live. If another sync has started in the meantime,
+// the buffer is kept for it.
+func (p *Processor) replay(ctx context.Context, buffered []kubeview.Event, out chan<- kubeview.Event) []kubeview.Event {
+	if !p.transition(StateSyncing, StateReplaying) {
+		return buffered
+	}
+
+	// Decide what to replay before forwarding anything, as forwarded events update the store.
+	pending := make([]kubeview.Event, 0, len(buffered))
+	for _, event := range buffered {
+		if !p.inSnapshot(event) {
+			pending = append(pending, event)
+		}
+	}
+	for _, event := range pending {
+		select {
+		case out <- event:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	p.transition(StateReplaying, StateLive)
+	slog.InfoContext(ctx, "replayed events buffered during sync", "buffered", len(buffered), "replayed", len(pending))
+	return nil
+}
+
+// inSnapshot reports whether the synced snapshot already contains the state an add or update carries.
+// Deletes are always replayed: the snapshot may predate them, and deleting a missing node is harmless.
+func (p *Processor) inSnapshot(event kubeview.Event) bool {
+	if event.Type == kubeview.EventTypeDelete {
+		return false
+	}
+	stored, ok := p.store.Get(event.Object.Metadata.UID)
+	if !ok {
+		return false
+	}
+	storedRevision, okStored := stored.Metadata.Revision()
+	eventRevision, okEvent := event.Object.Metadata.Revision()
+	return okStored && okEvent && eventRevision <= storedRevision
+}
+
+// forwardBuffered forwards the events already waiting in in without waiting for more.
+func forwardBuffered(in <-chan kubeview.Event, out chan<- kubeview.Event) {
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			out <- event
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startGate runs the event gate of p between two test channels.
+func startGate(t *testing.T, p *Processor) (chan<- kubeview.Event, <-chan kubeview.Event) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	in := make(chan kubeview.Event)
+	out := make(chan kubeview.Event, 100)
+	go p.gate(ctx, in, out)
+	return in, out
+}
+
+func receive(t *testing.T, out <-chan kubeview.Event, n int) []kubeview.Event {
+	t.Helper()
+	var events []kubeview.Event
+	for range n {
+		select {
+		case event := <-out:
+			events = append(events, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after receiving %d of %d events", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestGate_ForwardsEventsWhenLive(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	in, out := startGate(t, p)
+
+	in <- eventFor("a", "1")
+
+	assert.Equal(t, []kubeview.Event{eventFor("a", "1")}, receive(t, out, 1))
+	assert.Equal(t, StateLive, p.State())
+}
+
+func TestGate_ReplaysEventsNewerThanSnapshot(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	p.beginSync()
+	in, out := startGate(t, p)
+
+	in <- eventFor("in-snapshot", "5")
+	in <- eventFor("newer", "7")
+	in <- typedEvent(kubeview.EventTypeDelete, "deleted", "9")
+	in <- typedEvent(kubeview.EventTypeAdd, "created", "8")
+	select {
+	case event := <-out:
+		t.Fatalf("event applied during sync: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, StateSyncing, p.State())
+
+	// The snapshot written by the sync.
+	snapshot := []kubeview.KubernetesResource{
+		eventFor("in-snapshot", "6").Object,
+		eventFor("newer", "6").Object,
+		eventFor("deleted", "4").Object,
+	}
+	p.store.ReplaceNamespace("", snapshot)
+	p.endSync()
+
+	assert.Equal(t, []kubeview.Event{
+		eventFor("newer", "7"),
+		typedEvent(kubeview.EventTypeDelete, "deleted", "9"),
+		typedEvent(kubeview.EventTypeAdd, "created", "8"),
+	}, receive(t, out, 3))
+	require.Eventually(t, func() bool { return p.State() == StateLive }, 5*time.Second, 10*time.Millisecond)
+
+	in <- eventFor("after", "10")
+	assert.Equal(t, []kubeview.Event{eventFor("after", "10")}, receive(t, out, 1))
+}
+
+func TestGate_WaitsForOverlappingSyncs(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	p.beginSync()
+	p.beginSync()
+	in, out := startGate(t, p)
+
+	in <- eventFor("a", "1")
+	p.endSync()
+	select {
+	case event := <-out:
+		t.Fatalf("event applied while a sync is still running: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.endSync()
+	assert.Equal(t, []kubeview.Event{eventFor("a", "1")}, receive(t, out, 1))
+}
+
+func TestGate_PausesWhenReplayBufferIsFull(t *testing.T) {
+	p := NewProcessor(nil, nil, WithReplayBuffer(2))
+	p.beginSync()
+	in, out := startGate(t, p)
+
+	in <- eventFor("a", "1")
+	in <- eventFor("b", "1")
+	select {
+	case in <- eventFor("c", "1"):
+		t.Fatal("gate accepted an event beyond the replay buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 2, p.BufferedEvents())
+
+	p.endSync()
+	in <- eventFor("c", "1")
+	assert.Equal(t, []kubeview.Event{eventFor("a", "1"), eventFor("b", "1"), eventFor("c", "1")}, receive(t, out, 3))
+}
+
+func TestGate_AppliesEventsDuringRefresh(t *testing.T) {
+	listing, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		listing <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	p := NewProcessor(kubeview.NewClient(server.URL), nil)
+	in, out := startGate(t, p)
+	refreshed := make(chan error, 1)
+	go func() { refreshed <- p.Refresh(context.Background(), Scope{}, nil) }()
+	<-listing
+
+	assert.Equal(t, StateLive, p.State())
+	in <- eventFor("a", "1")
+	assert.Equal(t, []kubeview.Event{eventFor("a", "1")}, receive(t, out, 1))
+
+	close(release)
+	assert.Error(t, <-refreshed)
+}
+
+func TestInitialSync_RetriesListingNamespaces(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var listed atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if listed.Add(1) == 3 {
+			cancel()
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	noRetries := kubeview.RetryPolicy{MaxAttempts: 1}
+	p := NewProcessor(kubeview.NewClient(server.URL, kubeview.WithRetryPolicy(noRetries)), nil,
+		WithNamespaceRetryPolicy(kubeview.RetryPolicy{MaxAttempts: 1, InitialDelay: time.Millisecond}))
+
+	assert.ErrorIs(t, p.InitialSync(ctx), context.Canceled)
+	assert.Equal(t, int64(3), listed.Load())
+	assert.False(t, p.InitialSyncCompleted())
+}
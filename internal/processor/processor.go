@@ -2,165 +2,634 @@ package processor
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"kube-kg/internal/cache"
 	"kube-kg/internal/graph"
 	"kube-kg/internal/kubeview"
 	"kube-kg/internal/neo4j"
 
+	neo4jdriver "github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// generationProperty is the node and relationship property holding the sync generation that last touched it.
+const generationProperty = "syncGeneration"
+
 // Processor handles the synchronization of Kubernetes data to Neo4j.
 
 type Processor struct {
 	kubeClient  *kubeview.Client
 	neo4jClient *neo4j.Client
+	// store mirrors the cluster state so that events can be linked to the
+	// resources they reference.
+	store *cache.Store
+	// generation identifies the most recent sync. Everything written is stamped
+	// with it so that elements not seen by a sync can be pruned afterwards.
+	generation atomic.Int64
+	// syncMu serializes full and targeted syncs.
+	syncMu sync.Mutex
+	// namespaces lists the namespaces found by the last full sync, before filtering.
+	namespaces   []string
+	namespacesMu sync.Mutex
+	// namespaceFilter selects the namespaces kept in the graph. It can be replaced while running.
+	namespaceFilter atomic.Pointer[NamespaceFilter]
+
+	eventWorkers   int
+	eventQueueSize int
+	coalesceWindow time.Duration
+	events         *workerPool
+
+	// state is the lifecycle state of the event processor. Events received while
+	// syncing are buffered, up to replayBufferSize, and replayed once syncDone fires.
+	state            State
+	activeSyncs      int
+	stateMu          sync.Mutex
+	syncDone         chan struct{}
+	replayBufferSize int
+	bufferedEvents   atomic.Int64
+	// initialSynced is set once a full sync has settled every namespace.
+	initialSynced atomic.Bool
+
+	// namespaceRetryPolicy governs how often a namespace is synchronized again after failing.
+	namespaceRetryPolicy kubeview.RetryPolicy
+	lastReport           *SyncReport
+	reportMu             sync.Mutex
+
+	// rejectedWrites counts writes skipped because a newer version of the resource was already stored,
+	// and nodesWritten the nodes written in committed transactions.
+	rejectedWrites metric.Int64Counter
+	nodesWritten   metric.Int64Counter
+	eventMetrics   eventMetrics
+
+	// syncFetchers and syncWriters limit how many namespaces a sync fetches and writes at once.
+	syncFetchers int
+	syncWriters  int
+	syncMetrics  syncMetrics
+
+	// resyncInterval and resyncMode configure the periodic resync. It is disabled when the interval is zero.
+	resyncInterval time.Duration
+	resyncMode     ResyncMode
+}
+
+// Option configures optional behaviour of a Processor.
+type Option func(*Processor)
+
+// WithEventWorkers sets the number of workers applying events and the capacity of each worker's queue.
+func WithEventWorkers(workers, queueSize int) Option {
+	return func(p *Processor) {
+		p.eventWorkers = workers
+		p.eventQueueSize = queueSize
+	}
+}
+
+// WithCoalescingWindow sets how long each worker collects events before writing them. Only the
+// latest event per resource within the window is written. Zero writes every event as it arrives.
+func WithCoalescingWindow(window time.Duration) Option {
+	return func(p *Processor) {
+		p.coalesceWindow = window
+	}
+}
+
+// WithReplayBuffer sets how many events are buffered while an initial sync is running.
+func WithReplayBuffer(size int) Option {
+	return func(p *Processor) {
+		p.replayBufferSize = size
+	}
+}
+
+// WithNamespaceRetryPolicy sets the policy for retrying a namespace whose sync failed.
+func WithNamespaceRetryPolicy(policy kubeview.RetryPolicy) Option {
+	return func(p *Processor) {
+		p.namespaceRetryPolicy = policy
+	}
+}
+
+// WithSyncConcurrency sets how many namespaces a sync fetches from KubeView and writes to Neo4j at once.
+// Writers are further limited to the Neo4j connections left over by the event workers.
+func WithSyncConcurrency(fetchers, writers int) Option {
+	return func(p *Processor) {
+		p.syncFetchers = fetchers
+		p.syncWriters = writers
+	}
+}
+
+// WithPeriodicResync resyncs the synchronized namespaces at the given interval, measuring how far the
+// graph has drifted from KubeView before fixing it.
+func WithPeriodicResync(interval time.Duration, mode ResyncMode) Option {
+	return func(p *Processor) {
+		p.resyncInterval = interval
+		p.resyncMode = mode
+	}
 }
 
-// NewProcessor creates a new Processor.
-func NewProcessor(kubeClient *kubeview.Client, neo4jClient *neo4j.Client) *Processor {
-	return &Processor{
-		kubeClient:  kubeClient,
-		neo4jClient: neo4jClient,
+// WithNamespaceFilter keeps only the namespaces the filter allows in the graph.
+func WithNamespaceFilter(filter NamespaceFilter) Option {
+	return func(p *Processor) {
+		p.namespaceFilter.Store(&filter)
 	}
 }
 
+// NewProcessor creates a new Processor. Until a sync is started, events are applied as they arrive.
+func NewProcessor(kubeClient *kubeview.Client, neo4jClient *neo4j.Client, opts ...Option) *Processor {
+	p := &Processor{
+		kubeClient:       kubeClient,
+		neo4jClient:      neo4jClient,
+		store:            cache.NewStore(),
+		eventWorkers:     DefaultEventWorkers,
+		eventQueueSize:   DefaultEventQueueSize,
+		state:            StateLive,
+		syncDone:         make(chan struct{}, 1),
+		replayBufferSize: DefaultReplayBufferSize,
+		syncFetchers:     DefaultSyncFetchers,
+		syncWriters:      DefaultSyncWriters,
+		resyncMode:       ResyncIncremental,
+		namespaceRetryPolicy: kubeview.RetryPolicy{
+			MaxAttempts:  3,
+			InitialDelay: time.Second,
+			MaxDelay:     10 * time.Second,
+			Multiplier:   2,
+			Jitter:       0.2,
+		},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	rejectedWrites, err := otel.Meter("kube-kg/internal/processor").Int64Counter("processor.writes.rejected",
+		metric.WithDescription("Writes skipped because a newer version of the resource was already stored"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.writes.rejected", "err", err)
+	}
+	nodesWritten, err := otel.Meter("kube-kg/internal/processor").Int64Counter("processor.nodes.written",
+		metric.WithDescription("Nodes written to the graph in committed transactions, by source"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.nodes.written", "err", err)
+	}
+	if p.namespaceFilter.Load() == nil {
+		p.namespaceFilter.Store(&NamespaceFilter{})
+	}
+	p.rejectedWrites = rejectedWrites
+	p.nodesWritten = nodesWritten
+	p.eventMetrics = newEventMetrics()
+	p.syncMetrics = newSyncMetrics()
+	return p
+}
+
+// StartInitialSync switches the processor to the syncing state and runs InitialSync in a new
+// goroutine. Calling it before events start to flow guarantees that none is applied ahead of the snapshot.
+func (p *Processor) StartInitialSync(ctx context.Context) {
+	p.beginSync()
+	go func() {
+		defer p.endSync()
+		slog.InfoContext(ctx, "starting initial cluster synchronization")
+		if err := p.InitialSync(ctx); err != nil {
+			slog.ErrorContext(ctx, "initial sync failed", "err", err)
+			return
+		}
+		slog.InfoContext(ctx, "initial cluster synchronization completed")
+	}()
+}
+
 // InitialSync performs an initial synchronization of the Kubernetes cluster state to Neo4j.
+// Once a namespace has been committed, its nodes and relationships left over from
+// earlier generations are pruned. Events received meanwhile are buffered and replayed
+// afterwards, whether or not the sync succeeds. Listing the namespaces is retried with
+// backoff until it succeeds or ctx is cancelled.
 func (p *Processor) InitialSync(ctx context.Context) error {
-	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "InitialSync")
+	p.beginSync()
+	defer p.endSync()
+	for attempt := 1; ; attempt++ {
+		err := p.fullSync(ctx, "InitialSync", syncOptions{full: true})
+		var syncErr *SyncError
+		if err == nil || errors.As(err, &syncErr) || ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "initial sync failed, retrying", "attempt", attempt, "err", err)
+		if err := p.namespaceRetryPolicy.Wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// Refresh synchronizes the resources within scope, telling observer about its progress. The zero
+// scope synchronizes every namespace like InitialSync, except that events keep being applied while
+// it runs. Otherwise only the namespaces in scope are fetched, and only nodes within scope are pruned.
+func (p *Processor) Refresh(ctx context.Context, scope Scope, observer SyncObserver) error {
+	if scope.IsZero() {
+		return p.fullSync(ctx, "Refresh", syncOptions{observer: observer, full: true})
+	}
+	if err := scope.Validate(); err != nil {
+		return err
+	}
+
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "ScopedRefresh", trace.WithAttributes(
+		attribute.StringSlice("scope.namespaces", scope.Namespaces),
+		attribute.StringSlice("scope.kinds", scope.Kinds),
+	))
+	defer span.End()
+
+	namespaces, filter, err := p.resolveScope(ctx, scope)
+	if err != nil {
+		return err
+	}
+	return p.syncNamespaces(ctx, namespaces, syncOptions{observer: observer, scope: &scope, filter: filter})
+}
+
+func (p *Processor) fullSync(ctx context.Context, name string, opts syncOptions) error {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, name)
 	defer span.End()
 
 	namespaceResult, err := p.kubeClient.ListNamespaces(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list namespaces: %w", err)
 	}
+	p.setSyncedNamespaces(namespaceResult.Namespaces)
 
-	for _, namespace := range namespaceResult.Namespaces {
-		span.AddEvent(fmt.Sprintf("processing namespace: %s", namespace))
-		rawResources, err := p.kubeClient.FetchNamespaceResources(ctx, namespace, "initial-sync")
-		if err != nil {
-			return fmt.Errorf("failed to fetch resources for namespace %s: %w", namespace, err)
-		}
+	return p.syncNamespaces(ctx, p.filter().filter(namespaceResult.Namespaces), opts)
+}
 
-		var resources []kubeview.KubernetesResource
-		for _, rawResourceArray := range rawResources {
-			var resourceSlice []kubeview.KubernetesResource
-			if err := json.Unmarshal(rawResourceArray, &resourceSlice); err != nil {
-				return fmt.Errorf("failed to unmarshal resource slice: %w", err)
-			}
-			resources = append(resources, resourceSlice...)
-		}
+// SetNamespaceFilter replaces the namespace filter. Events and syncs from then on follow the new filter;
+// namespaces it newly includes are synchronized by the next resync, and the ones it newly excludes are
+// left in the graph as they are.
+func (p *Processor) SetNamespaceFilter(filter NamespaceFilter) {
+	p.namespaceFilter.Store(&filter)
+}
 
-		tx, err := p.neo4jClient.Begin(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-		defer func() {
-			if err := tx.Close(ctx); err != nil {
-				slog.Error("failed to close transaction", "err", err)
-			}
-		}()
-
-		for _, resource := range resources {
-			span.AddEvent(fmt.Sprintf("processing resource: %s", resource.Metadata.Name))
-			node := graph.KubernetesResourceToNode(resource)
-			if err := p.neo4jClient.MergeNode(ctx, tx, node); err != nil {
-				if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-					slog.Error("failed to rollback transaction", "err", rollbackErr)
-				}
-				return fmt.Errorf("failed to merge node: %w", err)
-			}
-			relationships := graph.ExtractRelationships(resource, resources)
-			for _, rel := range relationships {
-				if err := p.neo4jClient.MergeRelationship(ctx, tx, rel); err != nil {
-					if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-						slog.Error("failed to rollback transaction", "err", rollbackErr)
-					}
-					return fmt.Errorf("failed to merge relationship: %w", err)
-				}
-			}
-		}
+func (p *Processor) filter() NamespaceFilter {
+	return *p.namespaceFilter.Load()
+}
 
-		if err := tx.Commit(ctx); err != nil {
-			return fmt.Errorf("failed to commit transaction: %w", err)
-		}
-	}
+// ResyncNamespaces re-synchronizes the given namespaces, pruning whatever they no longer contain.
+func (p *Processor) ResyncNamespaces(ctx context.Context, namespaces []string) error {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
 
-	return nil
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "ResyncNamespaces", trace.WithAttributes(
+		attribute.StringSlice("namespaces", namespaces),
+	))
+	defer span.End()
+
+	return p.syncNamespaces(ctx, namespaces, syncOptions{})
 }
 
-// StartEventProcessor starts a goroutine to process events from the KubeView SSE stream.
-func StartEventProcessor(ctx context.Context, eventChan <-chan kubeview.Event, neo4jClient *neo4j.Client) {
+// WatchStreamGaps starts a goroutine that resyncs the synchronized namespaces whenever
+// the SSE stream reports that events may have been missed.
+func (p *Processor) WatchStreamGaps(ctx context.Context, gaps <-chan kubeview.StreamGap) {
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
-				slog.Info("stopping event processor")
 				return
-			case event := <-eventChan:
-				processEvent(ctx, event, neo4jClient)
+			case gap := <-gaps:
+				p.handleStreamGap(ctx, gap)
 			}
 		}
 	}()
 }
 
-func processEvent(ctx context.Context, event kubeview.Event, neo4jClient *neo4j.Client) {
-	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "processEvent")
-	defer span.End()
+func (p *Processor) handleStreamGap(ctx context.Context, gap kubeview.StreamGap) {
+	namespaces := p.syncedNamespaces()
+	slog.WarnContext(ctx, "sse stream gap detected, resyncing", "reason", gap.Reason, "lastEventID", gap.LastEventID,
+		"namespaces", namespaces)
 
-	switch event.Type {
-	case "add", "update":
-		handleAddOrUpdate(ctx, event, neo4jClient)
-	case "delete":
-		handleDelete(ctx, event, neo4jClient)
-	default:
-		slog.Warn("unknown event type", "type", event.Type)
+	var err error
+	if len(namespaces) == 0 {
+		// The initial sync has not completed, so there is nothing to target yet.
+		err = p.InitialSync(ctx)
+	} else {
+		err = p.ResyncNamespaces(ctx, namespaces)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "gap resync failed", "err", err)
+		return
 	}
+	slog.InfoContext(ctx, "gap resync completed", "namespaces", len(namespaces))
 }
 
-func handleAddOrUpdate(ctx context.Context, event kubeview.Event, neo4jClient *neo4j.Client) {
-	node := graph.KubernetesResourceToNode(event.Object)
-	relationships := graph.ExtractRelationships(event.Object, nil) // In a real-time scenario, we might need to fetch related resources
+func (p *Processor) setSyncedNamespaces(namespaces []string) {
+	p.namespacesMu.Lock()
+	defer p.namespacesMu.Unlock()
+	p.namespaces = slices.Clone(namespaces)
+}
 
-	tx, err := neo4jClient.Begin(ctx)
+// syncedNamespaces returns the namespaces found by the last full sync that the filter allows.
+func (p *Processor) syncedNamespaces() []string {
+	p.namespacesMu.Lock()
+	defer p.namespacesMu.Unlock()
+	return p.filter().filter(p.namespaces)
+}
+
+// pruneNamespace removes the nodes and relationships within scope that were not stamped by generation.
+func (p *Processor) pruneNamespace(ctx context.Context, scope neo4j.PruneScope, generation int64) (neo4j.PruneResult, error) {
+	tx, err := p.neo4jClient.Begin(ctx)
 	if err != nil {
-		slog.Error("failed to begin transaction", "err", err)
-		return
+		return neo4j.PruneResult{}, fmt.Errorf("failed to begin prune transaction: %w", err)
 	}
 	defer func() {
 		if err := tx.Close(ctx); err != nil {
-			slog.Error("failed to close transaction", "err", err)
+			slog.ErrorContext(ctx, "failed to close transaction", "err", err)
 		}
 	}()
 
-	if err := neo4jClient.MergeNode(ctx, tx, node); err != nil {
-		slog.Error("failed to merge node", "err", err)
+	pruned, err := p.neo4jClient.PruneScope(ctx, tx, scope, generation)
+	if err != nil {
 		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-			slog.Error("failed to rollback transaction", "err", rollbackErr)
+			slog.ErrorContext(ctx, "failed to rollback transaction", "err", rollbackErr)
 		}
+		return neo4j.PruneResult{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return neo4j.PruneResult{}, fmt.Errorf("failed to commit prune transaction: %w", err)
+	}
+	p.syncMetrics.nodesPruned.Add(ctx, pruned.Nodes)
+
+	if pruned.Nodes > 0 || pruned.Relationships > 0 {
+		slog.InfoContext(ctx, "pruned stale namespace elements", "namespace", scope.Namespace,
+			"nodes", pruned.Nodes, "relationships", pruned.Relationships)
+	}
+	return pruned, nil
+}
+
+// recordStaleWrites counts and traces writes skipped because a newer version of the resource was already stored.
+func (p *Processor) recordStaleWrites(ctx context.Context, source string, uids []string) {
+	if len(uids) == 0 {
 		return
 	}
+	span := trace.SpanFromContext(ctx)
+	for _, uid := range uids {
+		span.AddEvent("rejected stale write", trace.WithAttributes(attribute.String("uid", uid)))
+	}
+	p.rejectedWrites.Add(ctx, int64(len(uids)), metric.WithAttributes(attribute.String("source", source)))
+	slog.DebugContext(ctx, "rejected stale writes", "source", source, "count", len(uids))
+}
+
+// recordWrites counts the nodes written by a committed transaction.
+func (p *Processor) recordWrites(ctx context.Context, source string, nodes int) {
+	if nodes > 0 {
+		p.nodesWritten.Add(ctx, int64(nodes), metric.WithAttributes(attribute.String("source", source)))
+	}
+}
+
+// stampGeneration records the sync generation on every node and relationship about to be written.
+func stampGeneration(nodes []graph.Node, relationships []graph.Relationship, generation int64) {
+	for i := range nodes {
+		nodes[i].Properties[generationProperty] = generation
+	}
+	for i := range relationships {
+		if relationships[i].Properties == nil {
+			relationships[i].Properties = make(map[string]interface{})
+		}
+		relationships[i].Properties[generationProperty] = generation
+	}
+}
+
+// uidSet returns the set of uids.
+func uidSet(uids []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(uids))
+	for _, uid := range uids {
+		set[uid] = struct{}{}
+	}
+	return set
+}
+
+// StartEventProcessor starts a pool of workers to process events from the KubeView SSE stream.
+// Events for the same resource are processed in order; events for different resources run concurrently.
+// While a sync is running, events are held back and replayed once it has finished.
+func (p *Processor) StartEventProcessor(ctx context.Context, eventChan <-chan kubeview.Event) {
+	gated := make(chan kubeview.Event)
+	go p.gate(ctx, eventChan, gated)
+
+	// The pool stops once the gate closes gated, which it does after forwarding what was received before shutdown.
+	p.events = newWorkerPool(p.eventWorkers, p.eventQueueSize, p.coalesceWindow, p.processEvents)
+	p.events.run(context.WithoutCancel(ctx), gated)
+	slog.InfoContext(ctx, "started event workers", "workers", p.eventWorkers, "queueSize", p.eventQueueSize,
+		"coalesceWindow", p.coalesceWindow)
+}
+
+// Drain waits until the events received before the event processor's context was cancelled
+// have been processed, or until ctx is done.
+func (p *Processor) Drain(ctx context.Context) error {
+	if p.events == nil {
+		return nil
+	}
+	if err := p.events.wait(ctx); err != nil {
+		return fmt.Errorf("failed to drain event queue: %w", err)
+	}
+	slog.InfoContext(ctx, "stopped event processor")
+	return nil
+}
+
+// eventResult is the outcome of processing an event.
+type eventResult string
+
+const (
+	// eventApplied means the event was written to the graph.
+	eventApplied eventResult = "applied"
+	// eventStale means a newer version of the resource was already stored.
+	eventStale eventResult = "stale"
+	// eventFailed means the transaction the event was written in failed.
+	eventFailed eventResult = "failed"
+	// eventIgnored means the event had an unknown type or was in a namespace left out by the filter.
+	eventIgnored eventResult = "ignored"
+)
+
+// eventMetrics counts the events received from the stream and how they were processed.
+type eventMetrics struct {
+	received  metric.Int64Counter
+	processed metric.Int64Counter
+}
+
+func newEventMetrics() eventMetrics {
+	meter := otel.Meter("kube-kg/internal/processor")
+	received, err := meter.Int64Counter("processor.events.received",
+		metric.WithDescription("Events received from the KubeView stream, by type"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.events.received", "err", err)
+	}
+	processed, err := meter.Int64Counter("processor.events.processed",
+		metric.WithDescription("Events processed, by type and result"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.events.processed", "err", err)
+	}
+	return eventMetrics{received: received, processed: processed}
+}
+
+func (m eventMetrics) recordReceived(ctx context.Context, event kubeview.Event) {
+	m.received.Add(ctx, 1, metric.WithAttributes(attribute.String("type", string(event.Type))))
+}
 
-	for _, rel := range relationships {
-		if err := neo4jClient.MergeRelationship(ctx, tx, rel); err != nil {
-			slog.Error("failed to merge relationship", "err", err)
-			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
-				slog.Error("failed to rollback transaction", "err", rollbackErr)
+func (m eventMetrics) recordProcessed(ctx context.Context, event kubeview.Event, result eventResult) {
+	m.processed.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("type", string(event.Type)),
+		attribute.String("result", string(result)),
+	))
+}
+
+// processEvents applies a batch of events, holding at most one event per resource, in a single transaction.
+// The store is updated first so that resources in the same batch can be linked to each other. The span
+// links to the spans in which the events were received, so that each can be followed into Neo4j.
+func (p *Processor) processEvents(ctx context.Context, events []kubeview.Event) {
+	links := make([]trace.Link, 0, len(events))
+	for _, event := range events {
+		if event.SpanContext.IsValid() {
+			links = append(links, trace.Link{SpanContext: event.SpanContext})
+		}
+	}
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "processEvents",
+		trace.WithAttributes(attribute.Int("events", len(events))),
+		trace.WithLinks(links...))
+	defer span.End()
+
+	var upserted []kubeview.KubernetesResource
+	var deleted []string
+	var applying []kubeview.Event
+	filter := p.filter()
+	for _, event := range events {
+		if !filter.Allows(event.Object.Metadata.Namespace) {
+			p.eventMetrics.recordProcessed(ctx, event, eventIgnored)
+			continue
+		}
+		switch event.Type {
+		case kubeview.EventTypeAdd, kubeview.EventTypeUpdate:
+			if !p.store.Upsert(event.Object) {
+				p.recordStaleWrites(ctx, "event", []string{event.Object.Metadata.UID})
+				p.eventMetrics.recordProcessed(ctx, event, eventStale)
+				continue
 			}
-			return
+			upserted = append(upserted, event.Object)
+		case kubeview.EventTypeDelete:
+			p.store.Delete(event.Object)
+			deleted = append(deleted, event.Object.Metadata.UID)
+		default:
+			slog.WarnContext(ctx, "unknown event type", "type", event.Type)
+			p.eventMetrics.recordProcessed(ctx, event, eventIgnored)
+			continue
 		}
+		applying = append(applying, event)
+	}
+
+	rejected, err := p.writeEvents(ctx, upserted, deleted)
+	stale := uidSet(rejected)
+	for _, event := range applying {
+		_, isStale := stale[event.Object.Metadata.UID]
+		switch {
+		case err != nil:
+			p.eventMetrics.recordProcessed(ctx, event, eventFailed)
+		case isStale:
+			p.eventMetrics.recordProcessed(ctx, event, eventStale)
+		default:
+			p.eventMetrics.recordProcessed(ctx, event, eventApplied)
+		}
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(ctx, "failed to apply events", "err", err, "events", len(events))
+		return
+	}
+	p.recordWrites(ctx, "event", len(upserted)-len(rejected))
+}
+
+// writeEvents applies the upserted and deleted resources in a single transaction, returning the uids
+// of the upserted resources that were rejected because a newer version was already stored.
+func (p *Processor) writeEvents(ctx context.Context, upserted []kubeview.KubernetesResource,
+	deleted []string) ([]string, error) {
+	tx, err := p.neo4jClient.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to close transaction", "err", err)
+		}
+	}()
+
+	rejected, err := p.applyEvents(ctx, tx, upserted, deleted)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			slog.ErrorContext(ctx, "failed to rollback transaction", "err", rollbackErr)
+		}
+		return nil, err
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		slog.Error("failed to commit transaction", "err", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return rejected, nil
 }
 
-func handleDelete(ctx context.Context, event kubeview.Event, neo4jClient *neo4j.Client) {
-	if err := neo4jClient.DeleteNode(ctx, event.Object.Metadata.UID); err != nil {
-		slog.Error("failed to delete node", "err", err)
+// applyEvents writes the upserted resources and their relationships and deletes the deleted ones within tx.
+// It returns the uids of the upserted resources whose write was rejected as stale.
+func (p *Processor) applyEvents(ctx context.Context, tx neo4jdriver.ExplicitTransaction,
+	upserted []kubeview.KubernetesResource, deleted []string) ([]string, error) {
+	span := trace.SpanFromContext(ctx)
+
+	// Stamping with the current generation keeps objects created while a sync is
+	// running from being pruned by that sync.
+	generation := p.generation.Load()
+	nodes := make([]graph.Node, 0, len(upserted))
+	for _, resource := range upserted {
+		nodes = append(nodes, graph.KubernetesResourceToNode(resource))
+	}
+	stampGeneration(nodes, nil, generation)
+
+	if err := p.neo4jClient.DeleteNodes(ctx, tx, deleted); err != nil {
+		return nil, err
+	}
+	rejected, err := p.neo4jClient.MergeNodes(ctx, tx, nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge nodes: %w", err)
+	}
+	// The relationships of a rejected resource were derived from its outdated state.
+	p.recordStaleWrites(ctx, "event", rejected)
+
+	stale := uidSet(rejected)
+	var relationshipsDeleted, relationshipsCreated int
+	for _, resource := range upserted {
+		if _, ok := stale[resource.Metadata.UID]; ok {
+			continue
+		}
+		outgoing := graph.ExtractOutgoingRelationships(resource, p.store)
+		incoming := graph.ExtractIncomingRelationships(resource, p.store)
+		stampGeneration(nil, outgoing, generation)
+		stampGeneration(nil, incoming, generation)
+
+		outgoingResult, err := p.neo4jClient.ReconcileRelationships(ctx, tx, resource.Metadata.UID, outgoing, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconcile outgoing relationships: %w", err)
+		}
+		// Of the incoming relationships only SELECTS depends on the resource itself (its
+		// labels); OWNS and MOUNTS are owned by the other end and are merged, not pruned.
+		incomingResult, err := p.neo4jClient.ReconcileIncomingRelationships(ctx, tx, resource.Metadata.UID, incoming,
+			[]string{"SELECTS"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconcile incoming relationships: %w", err)
+		}
+		relationshipsDeleted += outgoingResult.Deleted + incomingResult.Deleted
+		relationshipsCreated += outgoingResult.Created + incomingResult.Created
 	}
+
+	span.SetAttributes(
+		attribute.Int("nodes.merged", len(nodes)),
+		attribute.Int("nodes.deleted", len(deleted)),
+		attribute.Int("relationships.deleted", relationshipsDeleted),
+		attribute.Int("relationships.created", relationshipsCreated),
+	)
+	return rejected, nil
 }
@@ -5,6 +5,7 @@ import (
 	"kube-kg/internal/config"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -112,7 +113,8 @@ func TestEventProcessor(t *testing.T) {
 	defer func() { require.NoError(t, neo4jClient.Close(ctx)) }()
 
 	eventChan := make(chan kubeview.Event)
-	go StartEventProcessor(ctx, eventChan, neo4jClient)
+	processor := NewProcessor(nil, neo4jClient)
+	processor.StartEventProcessor(ctx, eventChan)
 
 	// Test ADD event
 	eventChan <- kubeview.Event{
@@ -164,3 +166,375 @@ func TestEventProcessor(t *testing.T) {
 
 	require.NoError(t, tx.Close(ctx))
 }
+
+// newTestNeo4jClient starts a Neo4j container and returns a client connected to it.
+func newTestNeo4jClient(t *testing.T, ctx context.Context) *neo4j.Client {
+	t.Helper()
+
+	neo4jContainer, err := neo4jcontainer.Run(ctx, "neo4j:5", neo4jcontainer.WithAdminPassword("password"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := neo4jContainer.Terminate(ctx); err != nil {
+			t.Fatalf("failed to terminate container: %s", err)
+		}
+	})
+
+	uri, err := neo4jContainer.BoltUrl(ctx)
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Neo4jURI:      uri,
+		Neo4jUser:     "neo4j",
+		Neo4jPassword: "password",
+	}
+
+	neo4jClient, err := neo4j.NewClient(ctx, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, neo4jClient.Close(ctx)) })
+
+	return neo4jClient
+}
+
+// countNodes returns the number of nodes matching the given Cypher pattern.
+func countNodes(t *testing.T, ctx context.Context, neo4jClient *neo4j.Client, query string) int64 {
+	t.Helper()
+
+	tx, err := neo4jClient.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, tx.Close(ctx)) }()
+
+	result, err := tx.Run(ctx, query, nil)
+	require.NoError(t, err)
+	require.True(t, result.Next(ctx))
+	count, _ := result.Record().Values[0].(int64)
+	return count
+}
+
+func TestInitialSync_PrunesStaleNodes(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":["default"]}`))
+		case "/api/fetch/default":
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "live-pod", "namespace": "default", "uid": "live-pod-uid"}}
+				]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+
+	// Seed a pod that was deleted from the cluster while kube-kg was not watching,
+	// plus one in another namespace that must be left alone.
+	tx, err := neo4jClient.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Run(ctx, `
+		CREATE (:KubernetesResource:Pod {uid: 'ghost-pod-uid', namespace: 'default', syncGeneration: 1})
+		CREATE (:KubernetesResource:Pod {uid: 'other-pod-uid', namespace: 'other', syncGeneration: 1})
+	`, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, tx.Close(ctx))
+
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.InitialSync(ctx))
+
+	assert.True(t, processor.InitialSyncCompleted())
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'live-pod-uid'}) RETURN count(n)"))
+	assert.Equal(t, int64(0), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'ghost-pod-uid'}) RETURN count(n)"))
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'other-pod-uid'}) RETURN count(n)"))
+}
+
+func TestInitialSync_IsolatesNamespaceFailures(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":["restricted","partial","default"]}`))
+		case "/api/fetch/restricted":
+			w.WriteHeader(http.StatusForbidden)
+		case "/api/fetch/partial":
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "p", "namespace": "partial", "uid": "partial-pod-uid"}}
+				],
+				"services": {"not": "an array"}
+			}`))
+		case "/api/fetch/default":
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "p", "namespace": "default", "uid": "default-pod-uid"}}
+				]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	err := processor.InitialSync(ctx)
+
+	var syncErr *SyncError
+	require.ErrorAs(t, err, &syncErr)
+	assert.Equal(t, []string{"restricted"}, syncErr.Namespaces)
+	assert.True(t, processor.InitialSyncCompleted(), "a forbidden namespace does not hold up readiness")
+	assert.Equal(t, int64(2), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod) RETURN count(n)"))
+
+	report := processor.LastSyncReport()
+	require.NotNil(t, report)
+	require.Len(t, report.Namespaces, 3)
+	assert.Equal(t, NamespaceFailed, report.Namespaces[0].Status)
+	assert.Equal(t, 1, report.Namespaces[0].Attempts, "a 403 is not retried")
+	assert.NotEmpty(t, report.Namespaces[0].Error)
+	assert.Equal(t, NamespacePartial, report.Namespaces[1].Status)
+	assert.Equal(t, []string{"services"}, report.Namespaces[1].SkippedKinds)
+	assert.Equal(t, NamespaceSynced, report.Namespaces[2].Status)
+	assert.Equal(t, 1, report.Namespaces[2].Resources)
+}
+
+func TestInitialSync_SkipsRelationshipsOfRejectedNodes(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":["default"]}`))
+		case "/api/fetch/default":
+			_, _ = w.Write([]byte(`{
+				"replicasets": [
+					{"apiVersion": "apps/v1", "kind": "ReplicaSet",
+					 "metadata": {"name": "rs", "namespace": "default", "uid": "rs-uid", "resourceVersion": "5"}}
+				],
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod", "namespace": "default", "uid": "pod-uid",
+					 "resourceVersion": "5", "ownerReferences": [{"uid": "rs-uid"}]}}
+				]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+
+	// An event has already written a newer version of the pod, which no longer has an owner.
+	tx, err := neo4jClient.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Run(ctx, `
+		CREATE (:KubernetesResource:Pod {uid: 'pod-uid', namespace: 'default', resourceVersion: 9, syncGeneration: 1})
+	`, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, tx.Close(ctx))
+
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.InitialSync(ctx))
+
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:ReplicaSet {uid: 'rs-uid'}) RETURN count(n)"))
+	assert.Equal(t, int64(0), countNodes(t, ctx, neo4jClient, "MATCH (:Pod {uid: 'pod-uid'})-[r:OWNS]->() RETURN count(r)"))
+	report := processor.LastSyncReport()
+	require.NotNil(t, report)
+	assert.Equal(t, 0, report.Namespaces[0].Relationships)
+}
+
+func TestRefresh_PrunesOnlyWithinScope(t *testing.T) {
+	ctx := context.Background()
+
+	fetched := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		switch r.URL.Path {
+		case "/api/fetch/default":
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "live-pod", "namespace": "default", "uid": "live-pod-uid"}}
+				],
+				"services": []
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+
+	// Both stale nodes are gone from the cluster, but only the pod is in scope.
+	tx, err := neo4jClient.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Run(ctx, `
+		CREATE (:KubernetesResource:Pod {uid: 'ghost-pod-uid', kind: 'Pod', namespace: 'default', syncGeneration: 1})
+		CREATE (:KubernetesResource:Service {uid: 'ghost-svc-uid', kind: 'Service', namespace: 'default', syncGeneration: 1})
+	`, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, tx.Close(ctx))
+
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.Refresh(ctx, Scope{Namespaces: []string{"default"}, Kinds: []string{"Pod"}}, nil))
+
+	assert.Equal(t, []string{"/api/fetch/default"}, drain(fetched), "only the namespace in scope is fetched")
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'live-pod-uid'}) RETURN count(n)"))
+	assert.Equal(t, int64(0), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'ghost-pod-uid'}) RETURN count(n)"))
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:Service {uid: 'ghost-svc-uid'}) RETURN count(n)"))
+
+	report := processor.LastSyncReport()
+	require.NotNil(t, report)
+	assert.Equal(t, []string{"Pod"}, report.Scope.Kinds)
+	assert.Equal(t, int64(1), report.Namespaces[0].PrunedNodes)
+}
+
+func drain(paths chan string) []string {
+	var drained []string
+	for {
+		select {
+		case path := <-paths:
+			drained = append(drained, path)
+		default:
+			return drained
+		}
+	}
+}
+
+func TestRefresh_DoesNotResurrectResourcesDeletedDuringSync(t *testing.T) {
+	ctx := context.Background()
+
+	var processor *Processor
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":["default"]}`))
+		case "/api/fetch/default":
+			// The pod is deleted once KubeView has taken the snapshot, before the sync writes it.
+			deleted := typedEvent(kubeview.EventTypeDelete, "pod-uid", "6")
+			deleted.Object.Metadata.Namespace = "default"
+			processor.processEvents(ctx, []kubeview.Event{deleted})
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod",
+					 "metadata": {"name": "pod", "namespace": "default", "uid": "pod-uid", "resourceVersion": "5"}}
+				]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+	processor = NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.Refresh(ctx, Scope{}, nil))
+
+	assert.Equal(t, int64(0), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'pod-uid'}) RETURN count(n)"))
+	_, stored := processor.store.Get("pod-uid")
+	assert.False(t, stored)
+}
+
+func TestPeriodicResync_RemovesDroppedLabels(t *testing.T) {
+	ctx := context.Background()
+
+	var relabelled atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			_, _ = w.Write([]byte(`{"namespaces":["default"]}`))
+		case "/api/fetch/default":
+			metadata := `{"name": "pod", "namespace": "default", "uid": "pod-uid", "resourceVersion": "1",
+				"labels": {"app": "web"}}`
+			if relabelled.Load() {
+				metadata = `{"name": "pod", "namespace": "default", "uid": "pod-uid", "resourceVersion": "2"}`
+			}
+			_, _ = w.Write([]byte(`{"pods": [{"apiVersion": "v1", "kind": "Pod", "metadata": ` + metadata + `}]}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.InitialSync(ctx))
+
+	// The label is removed while no event reaches kube-kg.
+	relabelled.Store(true)
+	processor.periodicResync(ctx)
+	report := processor.LastSyncReport()
+	require.NotNil(t, report)
+	require.NotNil(t, report.Drift)
+	assert.Equal(t, Drift{Changed: 1}, *report.Drift)
+	assert.Equal(t, int64(0), countNodes(t, ctx, neo4jClient,
+		"MATCH (n:Pod {uid: 'pod-uid'}) WHERE n.`label.app` IS NOT NULL RETURN count(n)"))
+
+	processor.periodicResync(ctx)
+	report = processor.LastSyncReport()
+	require.NotNil(t, report)
+	require.NotNil(t, report.Drift)
+	assert.Equal(t, Drift{}, *report.Drift, "the resync has converged")
+}
+
+func TestPeriodicResync_SyncsNewNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	var created atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/namespaces":
+			if created.Load() {
+				_, _ = w.Write([]byte(`{"namespaces":["default","team-a"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"namespaces":["default"]}`))
+		case "/api/fetch/default":
+			_, _ = w.Write([]byte(`{"pods": []}`))
+		case "/api/fetch/team-a":
+			_, _ = w.Write([]byte(`{
+				"pods": [
+					{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "pod", "namespace": "team-a", "uid": "pod-uid"}}
+				]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+	processor := NewProcessor(kubeview.NewClient(server.URL), neo4jClient)
+	require.NoError(t, processor.InitialSync(ctx))
+
+	created.Store(true)
+	processor.periodicResync(ctx)
+
+	assert.Equal(t, int64(1), countNodes(t, ctx, neo4jClient, "MATCH (n:Pod {uid: 'pod-uid'}) RETURN count(n)"))
+}
+
+func TestEventProcessor_LinksResourcesInEitherOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	neo4jClient := newTestNeo4jClient(t, ctx)
+
+	eventChan := make(chan kubeview.Event)
+	processor := NewProcessor(nil, neo4jClient)
+	processor.StartEventProcessor(ctx, eventChan)
+
+	// The service arrives before the pod it selects, the config map after the pod mounting it.
+	eventChan <- kubeview.Event{Type: "add", Object: kubeview.KubernetesResource{
+		Kind:     "Service",
+		Metadata: kubeview.ObjectMeta{Name: "web", Namespace: "default", UID: "svc-uid"},
+		Spec:     []byte(`{"selector":{"app":"web"}}`),
+	}}
+	eventChan <- kubeview.Event{Type: "add", Object: kubeview.KubernetesResource{
+		Kind: "Pod",
+		Metadata: kubeview.ObjectMeta{Name: "web-1", Namespace: "default", UID: "pod-uid",
+			Labels: map[string]string{"app": "web"}},
+		Spec: []byte(`{"volumes":[{"name":"config","configMap":{"name":"web-config"}}]}`),
+	}}
+	eventChan <- kubeview.Event{Type: "add", Object: kubeview.KubernetesResource{
+		Kind:     "ConfigMap",
+		Metadata: kubeview.ObjectMeta{Name: "web-config", Namespace: "default", UID: "cm-uid"},
+	}}
+
+	assert.Eventually(t, func() bool {
+		return countNodes(t, ctx, neo4jClient,
+			"MATCH (:Service {uid: 'svc-uid'})-[:SELECTS]->(:Pod {uid: 'pod-uid'})-[:MOUNTS]->(:ConfigMap {uid: 'cm-uid'}) RETURN count(*)") == 1
+	}, 10*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kube-kg/internal/kubeview"
+)
+
+// NamespaceStatus is the outcome of synchronizing one namespace.
+type NamespaceStatus string
+
+const (
+	// NamespaceSynced means every resource of the namespace was written and stale elements were pruned.
+	NamespaceSynced NamespaceStatus = "synced"
+	// NamespacePartial means some resource kinds could not be decoded. The others were written,
+	// but nothing was pruned, as the skipped kinds would have been removed from the graph.
+	NamespacePartial NamespaceStatus = "partial"
+	// NamespaceFailed means nothing from the namespace was written.
+	NamespaceFailed NamespaceStatus = "failed"
+)
+
+// NamespaceReport describes the synchronization of one namespace.
+type NamespaceReport struct {
+	Namespace           string          `json:"namespace"`
+	Status              NamespaceStatus `json:"status"`
+	Attempts            int             `json:"attempts"`
+	Resources           int             `json:"resources"`
+	Relationships       int             `json:"relationships"`
+	PrunedNodes         int64           `json:"pruned_nodes"`
+	PrunedRelationships int64           `json:"pruned_relationships"`
+	SkippedKinds        []string        `json:"skipped_kinds,omitempty"`
+	Drift               *Drift          `json:"drift,omitempty"`
+	DurationSeconds     float64         `json:"duration_seconds"`
+	Error               string          `json:"error,omitempty"`
+}
+
+// SyncReport describes a full or targeted synchronization, namespace by namespace.
+type SyncReport struct {
+	Generation int64             `json:"generation"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Namespaces []NamespaceReport `json:"namespaces"`
+	// Scope is set if the sync was limited to part of the cluster.
+	Scope *Scope `json:"scope,omitempty"`
+	// Drift sums the drift of the namespaces, if the sync compared them with the graph.
+	Drift *Drift `json:"drift,omitempty"`
+}
+
+// Failed returns the namespaces that could not be synchronized.
+func (r *SyncReport) Failed() []string {
+	var failed []string
+	for _, ns := range r.Namespaces {
+		if ns.Status == NamespaceFailed {
+			failed = append(failed, ns.Namespace)
+		}
+	}
+	return failed
+}
+
+// SyncError is returned when a sync completes but some namespaces failed to synchronize.
+// The other namespaces were synchronized; the report has the details.
+type SyncError struct {
+	Namespaces []string
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("failed to sync %d namespaces: %s", len(e.Namespaces), strings.Join(e.Namespaces, ", "))
+}
+
+// LastSyncReport returns the report of the most recent sync, or nil if no sync has completed.
+func (p *Processor) LastSyncReport() *SyncReport {
+	p.reportMu.Lock()
+	defer p.reportMu.Unlock()
+	return p.lastReport
+}
+
+func (p *Processor) setLastSyncReport(report *SyncReport) {
+	p.reportMu.Lock()
+	defer p.reportMu.Unlock()
+	p.lastReport = report
+}
+
+// retryableSyncError reports whether synchronizing a namespace may succeed if repeated. Client errors
+// from KubeView, such as a 403 for a namespace outside the watched scope, and cancellations are final.
+func retryableSyncError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *kubeview.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+		return false
+	}
+	var syntaxErr *json.SyntaxError
+	return !errors.As(err, &syntaxErr)
+}
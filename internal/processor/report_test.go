@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryableSyncError(t *testing.T) {
+	var syntaxErr *json.SyntaxError
+	malformed := json.Unmarshal([]byte("{"), &struct{}{})
+	assert.ErrorAs(t, malformed, &syntaxErr)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &kubeview.StatusError{StatusCode: 502}, true},
+		{"forbidden namespace", fmt.Errorf("fetch: %w", &kubeview.StatusError{StatusCode: 403}), false},
+		{"malformed response", fmt.Errorf("decode: %w", malformed), false},
+		{"cancelled", fmt.Errorf("fetch: %w", context.Canceled), false},
+		{"neo4j error", errors.New("failed to merge nodes: connection reset"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryableSyncError(tt.err))
+		})
+	}
+}
+
+func TestSettled(t *testing.T) {
+	job := func(status NamespaceStatus, err error) *syncJob {
+		return &syncJob{report: &NamespaceReport{Status: status}, err: err}
+	}
+	forbidden := &kubeview.StatusError{StatusCode: 403}
+	unavailable := &kubeview.StatusError{StatusCode: 503}
+
+	assert.True(t, settled(nil))
+	assert.True(t, settled([]*syncJob{job(NamespaceSynced, nil), job(NamespacePartial, nil)}))
+	assert.True(t, settled([]*syncJob{job(NamespaceSynced, nil), job(NamespaceFailed, forbidden)}),
+		"a namespace KubeView may not read does not hold up readiness")
+	assert.False(t, settled([]*syncJob{job(NamespaceSynced, nil), job(NamespaceFailed, unavailable)}))
+}
+
+func TestSyncReport_Failed(t *testing.T) {
+	report := &SyncReport{Namespaces: []NamespaceReport{
+		{Namespace: "a", Status: NamespaceSynced},
+		{Namespace: "b", Status: NamespaceFailed},
+		{Namespace: "c", Status: NamespacePartial},
+		{Namespace: "d", Status: NamespaceFailed},
+	}}
+
+	assert.Equal(t, []string{"b", "d"}, report.Failed())
+	assert.EqualError(t, &SyncError{Namespaces: report.Failed()}, "failed to sync 2 namespaces: b, d")
+}
@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+)
+
+// Scope limits a refresh to part of the cluster. The zero Scope covers the whole cluster.
+type Scope struct {
+	// Namespaces to refresh. Empty means every namespace.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Kinds, such as "Pod", to refresh within the namespaces. Empty means every kind.
+	Kinds []string `json:"kinds,omitempty"`
+	// Resource selects a single resource. It cannot be combined with Namespaces or Kinds.
+	Resource *ResourceRef `json:"resource,omitempty"`
+}
+
+// ResourceRef identifies a resource either by uid or by namespace, kind and name.
+type ResourceRef struct {
+	UID       string `json:"uid,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// ScopeError is returned when a Scope is invalid or refers to an unknown resource.
+type ScopeError struct {
+	Reason string
+}
+
+func (e *ScopeError) Error() string {
+	return "invalid refresh scope: " + e.Reason
+}
+
+// IsZero reports whether the scope covers the whole cluster.
+func (s Scope) IsZero() bool {
+	return len(s.Namespaces) == 0 && len(s.Kinds) == 0 && s.Resource == nil
+}
+
+// Validate checks that the scope is well-formed.
+func (s Scope) Validate() error {
+	if s.Resource == nil {
+		return nil
+	}
+	if len(s.Namespaces) > 0 || len(s.Kinds) > 0 {
+		return &ScopeError{Reason: "resource cannot be combined with namespaces or kinds"}
+	}
+	ref := s.Resource
+	byName := ref.Namespace != "" || ref.Kind != "" || ref.Name != ""
+	switch {
+	case ref.UID != "" && byName:
+		return &ScopeError{Reason: "resource must be identified either by uid or by namespace, kind and name"}
+	case ref.UID == "" && (ref.Namespace == "" || ref.Kind == "" || ref.Name == ""):
+		return &ScopeError{Reason: "resource requires a uid or a namespace, kind and name"}
+	}
+	return nil
+}
+
+// resourceFilter selects the resources of a namespace that a scoped sync covers.
+type resourceFilter struct {
+	kinds []string
+	name  string
+}
+
+func (f *resourceFilter) matches(resource kubeview.KubernetesResource) bool {
+	if len(f.kinds) > 0 && !slices.Contains(f.kinds, resource.Kind) {
+		return false
+	}
+	return f.name == "" || resource.Metadata.Name == f.name
+}
+
+func (f *resourceFilter) pruneScope(namespace string) neo4j.PruneScope {
+	return neo4j.PruneScope{Namespace: namespace, Kinds: f.kinds, Name: f.name}
+}
+
+// resolveScope returns the namespaces a scope covers, leaving out those the namespace filter excludes,
+// and the filter for the resources within them. A resource given by uid is looked up in the store.
+func (p *Processor) resolveScope(ctx context.Context, scope Scope) ([]string, *resourceFilter, error) {
+	if ref := scope.Resource; ref != nil {
+		if ref.UID == "" {
+			return p.filter().filter([]string{ref.Namespace}), &resourceFilter{kinds: []string{ref.Kind}, name: ref.Name}, nil
+		}
+		resource, ok := p.store.Get(ref.UID)
+		if !ok {
+			return nil, nil, &ScopeError{Reason: fmt.Sprintf("unknown resource uid %s", ref.UID)}
+		}
+		return p.filter().filter([]string{resource.Metadata.Namespace}),
+			&resourceFilter{kinds: []string{resource.Kind}, name: resource.Metadata.Name}, nil
+	}
+
+	var filter *resourceFilter
+	if len(scope.Kinds) > 0 {
+		filter = &resourceFilter{kinds: scope.Kinds}
+	}
+	if len(scope.Namespaces) > 0 {
+		return p.filter().filter(scope.Namespaces), filter, nil
+	}
+	namespaceResult, err := p.kubeClient.ListNamespaces(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	return p.filter().filter(namespaceResult.Namespaces), filter, nil
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"testing"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   Scope
+		wantErr bool
+	}{
+		{name: "whole cluster", scope: Scope{}},
+		{name: "namespaces and kinds", scope: Scope{Namespaces: []string{"default"}, Kinds: []string{"Pod"}}},
+		{name: "resource by uid", scope: Scope{Resource: &ResourceRef{UID: "pod-uid"}}},
+		{name: "resource by name", scope: Scope{Resource: &ResourceRef{Namespace: "default", Kind: "Pod", Name: "web"}}},
+		{name: "resource without kind", scope: Scope{Resource: &ResourceRef{Namespace: "default", Name: "web"}}, wantErr: true},
+		{name: "resource by uid and name", scope: Scope{Resource: &ResourceRef{UID: "pod-uid", Name: "web"}}, wantErr: true},
+		{
+			name:    "resource and namespaces",
+			scope:   Scope{Namespaces: []string{"default"}, Resource: &ResourceRef{UID: "pod-uid"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.scope.Validate()
+			if tt.wantErr {
+				var scopeErr *ScopeError
+				assert.ErrorAs(t, err, &scopeErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolveScope_ResourceByUID(t *testing.T) {
+	p := NewProcessor(nil, nil)
+	p.store.Upsert(kubeview.KubernetesResource{
+		Kind:     "Pod",
+		Metadata: kubeview.ObjectMeta{Name: "web", Namespace: "team-a", UID: "pod-uid"},
+	})
+
+	namespaces, filter, err := p.resolveScope(t.Context(), Scope{Resource: &ResourceRef{UID: "pod-uid"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, namespaces)
+	assert.Equal(t, &resourceFilter{kinds: []string{"Pod"}, name: "web"}, filter)
+	assert.True(t, filter.matches(kubeview.KubernetesResource{Kind: "Pod", Metadata: kubeview.ObjectMeta{Name: "web"}}))
+	assert.False(t, filter.matches(kubeview.KubernetesResource{Kind: "Pod", Metadata: kubeview.ObjectMeta{Name: "db"}}))
+
+	_, _, err = p.resolveScope(t.Context(), Scope{Resource: &ResourceRef{UID: "unknown"}})
+	var scopeErr *ScopeError
+	assert.ErrorAs(t, err, &scopeErr)
+}
@@ -0,0 +1,484 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kube-kg/internal/graph"
+	"kube-kg/internal/kubeview"
+	"kube-kg/internal/neo4j"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultSyncFetchers is the number of namespaces fetched from KubeView at once when none is configured.
+	DefaultSyncFetchers = 4
+	// DefaultSyncWriters is the number of namespaces written to Neo4j at once when none is configured.
+	DefaultSyncWriters = 2
+)
+
+// syncOptions selects what a sync does besides writing the namespaces.
+type syncOptions struct {
+	// detectDrift compares each namespace with the graph before writing it.
+	detectDrift bool
+	// onlyDrifted skips writing namespaces without drift.
+	onlyDrifted bool
+	// observer, if set, is told about the progress of the sync.
+	observer SyncObserver
+	// scope and filter, if set, limit the sync to part of each namespace.
+	scope  *Scope
+	filter *resourceFilter
+	// full is set when the sync covers every namespace the filter allows.
+	full bool
+}
+
+// SyncObserver is notified of the progress of a sync. Its methods may be called from several goroutines.
+type SyncObserver interface {
+	// SyncStarted is called with the namespaces about to be synchronized.
+	SyncStarted(namespaces []string)
+	// NamespaceFinished is called as each namespace is synchronized or fails for good.
+	NamespaceFinished(report NamespaceReport)
+}
+
+// syncJob carries one namespace through the sync pipeline.
+type syncJob struct {
+	opts         syncOptions
+	report       *NamespaceReport
+	started      time.Time
+	resources    []kubeview.KubernetesResource
+	skippedKinds []string
+	// err is the error of the last failed attempt.
+	err error
+}
+
+// syncMetrics reports the progress of syncs.
+type syncMetrics struct {
+	namespaces    metric.Int64Counter
+	pending       metric.Int64UpDownCounter
+	stageDuration metric.Float64Histogram
+	duration      metric.Float64Histogram
+	drift         metric.Int64Counter
+	nodesPruned   metric.Int64Counter
+}
+
+func newSyncMetrics() syncMetrics {
+	meter := otel.Meter("kube-kg/internal/processor")
+	namespaces, err := meter.Int64Counter("processor.sync.namespaces",
+		metric.WithDescription("Namespaces synchronized, by outcome"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.sync.namespaces", "err", err)
+	}
+	pending, err := meter.Int64UpDownCounter("processor.sync.namespaces.pending",
+		metric.WithDescription("Namespaces of the running sync that are not finished yet"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.sync.namespaces.pending", "err", err)
+	}
+	stageDuration, err := meter.Float64Histogram("processor.sync.stage.duration",
+		metric.WithDescription("Time taken to fetch a namespace from KubeView or write it to Neo4j"),
+		metric.WithUnit("s"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.sync.stage.duration", "err", err)
+	}
+	duration, err := meter.Float64Histogram("processor.sync.duration",
+		metric.WithDescription("Time taken by a sync, by result"),
+		metric.WithUnit("s"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.sync.duration", "err", err)
+	}
+	drift, err := meter.Int64Counter("processor.drift.resources",
+		metric.WithDescription("Resources found to differ between KubeView and the graph by periodic resyncs"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.drift.resources", "err", err)
+	}
+	nodesPruned, err := meter.Int64Counter("processor.nodes.pruned",
+		metric.WithDescription("Nodes deleted from the graph by syncs because KubeView no longer contains them"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.nodes.pruned", "err", err)
+	}
+	return syncMetrics{
+		namespaces:    namespaces,
+		pending:       pending,
+		stageDuration: stageDuration,
+		duration:      duration,
+		drift:         drift,
+		nodesPruned:   nodesPruned,
+	}
+}
+
+// syncWriterLimit returns the number of concurrent Neo4j writers for a sync. It is capped so that the
+// writers and the event workers together never need more connections than the driver's pool holds.
+func (p *Processor) syncWriterLimit() int {
+	writers := p.syncWriters
+	if available := p.neo4jClient.MaxConnectionPoolSize() - p.eventWorkers; writers > available {
+		writers = max(available, 1)
+		slog.Warn("limiting sync writers to the Neo4j connection pool", "configured", p.syncWriters,
+			"writers", writers, "poolSize", p.neo4jClient.MaxConnectionPoolSize())
+	}
+	return max(writers, 1)
+}
+
+// syncNamespaces writes the current state of each namespace under a new sync generation and records
+// a SyncReport. Namespaces flow through a pipeline: up to syncFetchers are fetched from KubeView while
+// up to syncWriterLimit are written to Neo4j. They are synchronized independently, so one failing does
+// not stop the others; a SyncError lists those that failed. The caller must hold syncMu.
+func (p *Processor) syncNamespaces(ctx context.Context, namespaces []string, opts syncOptions) error {
+	span := trace.SpanFromContext(ctx)
+
+	generation := time.Now().UnixNano()
+	p.generation.Store(generation)
+	// Events are applied while the sync runs, so a resource may be deleted after its namespace was fetched.
+	p.store.TrackDeletes()
+	defer p.store.ForgetDeletes()
+	fetchers, writers := max(p.syncFetchers, 1), p.syncWriterLimit()
+	span.SetAttributes(
+		attribute.Int64("sync.generation", generation),
+		attribute.Int("sync.namespaces", len(namespaces)),
+		attribute.Int("sync.fetchers", fetchers),
+		attribute.Int("sync.writers", writers),
+	)
+
+	report := &SyncReport{Generation: generation, StartedAt: time.Now(), Scope: opts.scope}
+	report.Namespaces = make([]NamespaceReport, len(namespaces))
+
+	// Every namespace is queued at most once at a time, so sends to fetchQueue never block.
+	fetchQueue := make(chan *syncJob, len(namespaces))
+	writeQueue := make(chan *syncJob)
+	var pending sync.WaitGroup
+	pending.Add(len(namespaces))
+	p.syncMetrics.pending.Add(ctx, int64(len(namespaces)))
+	jobs := make([]*syncJob, len(namespaces))
+	for i, namespace := range namespaces {
+		report.Namespaces[i] = NamespaceReport{Namespace: namespace, Attempts: 1}
+		jobs[i] = &syncJob{opts: opts, report: &report.Namespaces[i], started: time.Now()}
+		fetchQueue <- jobs[i]
+	}
+	go func() {
+		pending.Wait()
+		close(fetchQueue)
+	}()
+	if opts.observer != nil {
+		opts.observer.SyncStarted(slices.Clone(namespaces))
+	}
+
+	var completed atomic.Int64
+	finish := func(job *syncJob) {
+		job.report.DurationSeconds = time.Since(job.started).Seconds()
+		p.syncMetrics.pending.Add(ctx, -1)
+		p.syncMetrics.namespaces.Add(ctx, 1, metric.WithAttributes(attribute.String("status", string(job.report.Status))))
+		span.AddEvent("namespace finished", trace.WithAttributes(
+			attribute.String("namespace", job.report.Namespace),
+			attribute.String("status", string(job.report.Status)),
+			attribute.Int64("completed", completed.Add(1)),
+			attribute.Int("total", len(namespaces)),
+		))
+		if opts.observer != nil {
+			opts.observer.NamespaceFinished(*job.report)
+		}
+		pending.Done()
+	}
+	// retry puts a failed namespace back in the fetch queue after a delay, or finishes it as failed.
+	retry := func(job *syncJob, err error) {
+		job.err = err
+		job.report.Status = NamespaceFailed
+		job.report.Error = err.Error()
+		if job.report.Attempts >= p.namespaceRetryPolicy.MaxAttempts || !retryableSyncError(err) {
+			slog.ErrorContext(ctx, "failed to sync namespace", "namespace", job.report.Namespace,
+				"attempts", job.report.Attempts, "err", err)
+			finish(job)
+			return
+		}
+		slog.WarnContext(ctx, "namespace sync failed, retrying", "namespace", job.report.Namespace,
+			"attempt", job.report.Attempts, "err", err)
+		go func() {
+			if err := p.namespaceRetryPolicy.Wait(ctx, job.report.Attempts); err != nil {
+				finish(job)
+				return
+			}
+			job.report.Attempts++
+			fetchQueue <- job
+		}()
+	}
+
+	var fetching sync.WaitGroup
+	for range fetchers {
+		fetching.Add(1)
+		go func() {
+			defer fetching.Done()
+			for job := range fetchQueue {
+				if err := p.fetchNamespace(ctx, job); err != nil {
+					retry(job, err)
+					continue
+				}
+				writeQueue <- job
+			}
+		}()
+	}
+	go func() {
+		fetching.Wait()
+		close(writeQueue)
+	}()
+
+	var writing sync.WaitGroup
+	for range writers {
+		writing.Add(1)
+		go func() {
+			defer writing.Done()
+			for job := range writeQueue {
+				if err := p.writeNamespace(ctx, job, generation); err != nil {
+					retry(job, err)
+					continue
+				}
+				job.err, job.report.Error = nil, ""
+				finish(job)
+			}
+		}()
+	}
+	writing.Wait()
+
+	report.FinishedAt = time.Now()
+
+	var prunedNodes, prunedRelationships int64
+	for _, ns := range report.Namespaces {
+		prunedNodes += ns.PrunedNodes
+		prunedRelationships += ns.PrunedRelationships
+		if ns.Drift != nil {
+			if report.Drift == nil {
+				report.Drift = &Drift{}
+			}
+			report.Drift.Missing += ns.Drift.Missing
+			report.Drift.Extra += ns.Drift.Extra
+			report.Drift.Changed += ns.Drift.Changed
+		}
+	}
+	p.setLastSyncReport(report)
+	if opts.full && settled(jobs) {
+		p.initialSynced.Store(true)
+	}
+	failed := report.Failed()
+	span.SetAttributes(
+		attribute.Int64("sync.pruned.nodes", prunedNodes),
+		attribute.Int64("sync.pruned.relationships", prunedRelationships),
+		attribute.Int("sync.namespaces.failed", len(failed)),
+	)
+	slog.InfoContext(ctx, "pruned stale graph elements", "nodes", prunedNodes, "relationships", prunedRelationships,
+		"generation", generation)
+
+	result := "succeeded"
+	if len(failed) > 0 {
+		result = "failed"
+	}
+	p.syncMetrics.duration.Record(ctx, report.FinishedAt.Sub(report.StartedAt).Seconds(),
+		metric.WithAttributes(attribute.String("result", result)))
+
+	if len(failed) > 0 {
+		return &SyncError{Namespaces: failed}
+	}
+	return nil
+}
+
+// settled reports whether every namespace was synchronized or failed with an error that retrying
+// would not fix, such as a 403 for a namespace KubeView may not read.
+func settled(jobs []*syncJob) bool {
+	for _, job := range jobs {
+		if job.report.Status == NamespaceFailed && retryableSyncError(job.err) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchNamespace fetches the resources of a namespace from KubeView. Resource kinds that cannot be
+// decoded are skipped and recorded in the job.
+func (p *Processor) fetchNamespace(ctx context.Context, job *syncJob) error {
+	namespace := job.report.Namespace
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "fetchNamespace", trace.WithAttributes(
+		attribute.String("namespace", namespace),
+		attribute.Int("attempt", job.report.Attempts),
+	))
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		p.syncMetrics.stageDuration.Record(ctx, time.Since(start).Seconds(),
+			metric.WithAttributes(attribute.String("stage", "fetch")))
+	}()
+
+	rawResources, err := p.kubeClient.FetchNamespaceResources(ctx, namespace, "initial-sync")
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to fetch resources for namespace %s: %w", namespace, err)
+	}
+
+	job.resources, job.skippedKinds = nil, nil
+	for _, kind := range slices.Sorted(maps.Keys(rawResources)) {
+		var resourceSlice []kubeview.KubernetesResource
+		if err := json.Unmarshal(rawResources[kind], &resourceSlice); err != nil {
+			slog.ErrorContext(ctx, "skipping malformed resource array", "namespace", namespace, "kind", kind, "err", err)
+			job.skippedKinds = append(job.skippedKinds, kind)
+			continue
+		}
+		for _, resource := range resourceSlice {
+			if job.opts.filter == nil || job.opts.filter.matches(resource) {
+				job.resources = append(job.resources, resource)
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("resources", len(job.resources)))
+	return nil
+}
+
+// writeNamespace writes a fetched namespace in a single transaction and prunes what it no longer
+// contains, recording the outcome in the job's report. A namespace with skipped kinds is not pruned.
+func (p *Processor) writeNamespace(ctx context.Context, job *syncJob, generation int64) error {
+	namespace := job.report.Namespace
+	ctx, span := otel.Tracer("kube-kg/internal/processor").Start(ctx, "writeNamespace", trace.WithAttributes(
+		attribute.String("namespace", namespace),
+		attribute.Int("resources", len(job.resources)),
+	))
+	defer span.End()
+	start := time.Now()
+	defer func() {
+		p.syncMetrics.stageDuration.Record(ctx, time.Since(start).Seconds(),
+			metric.WithAttributes(attribute.String("stage", "write")))
+	}()
+
+	if err := p.writeNamespaceResources(ctx, job, generation); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (p *Processor) writeNamespaceResources(ctx context.Context, job *syncJob, generation int64) error {
+	namespace := job.report.Namespace
+	resources := job.resources
+	report := job.report
+
+	var kept []string
+	switch {
+	case len(job.skippedKinds) == 0 && job.opts.filter != nil:
+		kept = p.store.ReplaceMatching(namespace, job.opts.filter.matches, resources)
+	case len(job.skippedKinds) == 0:
+		kept = p.store.ReplaceNamespace(namespace, resources)
+	default:
+		// Replacing the namespace would drop the skipped kinds from the store.
+		for _, resource := range resources {
+			if p.store.Upsert(resource) {
+				continue
+			}
+			if _, ok := p.store.Get(resource.Metadata.UID); ok {
+				kept = append(kept, resource.Metadata.UID)
+			}
+		}
+	}
+	p.recordStaleWrites(ctx, "sync", kept)
+	// Events may have delivered newer versions of resources or deleted them while the namespace was
+	// being fetched. Write what the store holds instead, leaving out the resources deleted since.
+	current := make([]kubeview.KubernetesResource, 0, len(resources))
+	for _, resource := range resources {
+		if stored, ok := p.store.Get(resource.Metadata.UID); ok {
+			current = append(current, stored)
+		}
+	}
+	resources = current
+
+	tx, err := p.neo4jClient.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "failed to close transaction", "err", err)
+		}
+	}()
+
+	nodes := make([]graph.Node, 0, len(resources))
+	var relationships []graph.Relationship
+	for _, resource := range resources {
+		nodes = append(nodes, graph.KubernetesResourceToNode(resource))
+		relationships = append(relationships, graph.ExtractOutgoingRelationships(resource, p.store)...)
+		if job.opts.filter != nil {
+			// Resources outside the scope are not written, so link them to the scoped ones here.
+			relationships = append(relationships, graph.ExtractIncomingRelationships(resource, p.store)...)
+		}
+	}
+
+	if job.opts.detectDrift && len(job.skippedKinds) == 0 {
+		// Skipped kinds would be counted as extra nodes, so partial namespaces are not compared.
+		stored, err := p.neo4jClient.NamespaceNodes(ctx, tx, namespace)
+		if err != nil {
+			return err
+		}
+		drift := diffNamespace(nodes, stored)
+		report.Drift = &drift
+		p.recordDrift(ctx, namespace, drift)
+		if job.opts.onlyDrifted && drift.Total() == 0 {
+			report.Resources = len(nodes)
+			report.Relationships = len(relationships)
+			report.Status = NamespaceSynced
+			return nil
+		}
+	}
+
+	stampGeneration(nodes, relationships, generation)
+
+	rejected, err := p.neo4jClient.MergeNodes(ctx, tx, nodes)
+	if err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			slog.ErrorContext(ctx, "failed to rollback transaction", "err", rollbackErr)
+		}
+		return fmt.Errorf("failed to merge nodes: %w", err)
+	}
+	p.recordStaleWrites(ctx, "sync", rejected)
+	// The relationships of a rejected resource were derived from its outdated state.
+	if len(rejected) > 0 {
+		stale := uidSet(rejected)
+		relationships = slices.DeleteFunc(relationships, func(relationship graph.Relationship) bool {
+			_, ok := stale[relationship.SourceID]
+			return ok
+		})
+	}
+	if err := p.neo4jClient.MergeRelationships(ctx, tx, relationships); err != nil {
+		if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+			slog.ErrorContext(ctx, "failed to rollback transaction", "err", rollbackErr)
+		}
+		return fmt.Errorf("failed to merge relationships: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	p.recordWrites(ctx, "sync", len(nodes)-len(rejected))
+
+	report.Resources = len(nodes)
+	report.Relationships = len(relationships)
+	report.SkippedKinds = job.skippedKinds
+	if len(job.skippedKinds) > 0 {
+		report.Status = NamespacePartial
+		return nil
+	}
+
+	pruneScope := neo4j.PruneScope{Namespace: namespace}
+	if job.opts.filter != nil {
+		pruneScope = job.opts.filter.pruneScope(namespace)
+	}
+	pruned, err := p.pruneNamespace(ctx, pruneScope, generation)
+	if err != nil {
+		return err
+	}
+	report.Status = NamespaceSynced
+	report.PrunedNodes = pruned.Nodes
+	report.PrunedRelationships = pruned.Relationships
+	return nil
+}
@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kube-kg/internal/kubeview"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// DefaultEventWorkers is the number of workers applying events when none is configured.
+	DefaultEventWorkers = 4
+	// DefaultEventQueueSize is the capacity of each worker's queue when none is configured.
+	DefaultEventQueueSize = 256
+)
+
+// workerPool applies events on a fixed number of workers. Events are sharded by
+// resource UID, so events for the same object are applied in the order received.
+// With a coalescing window, each worker collects events for that long, keeps only the
+// latest per UID and applies them as one batch.
+type workerPool struct {
+	handle func(ctx context.Context, events []kubeview.Event)
+	window time.Duration
+	queues []chan kubeview.Event
+	wg     sync.WaitGroup
+	done   chan struct{}
+	// progress is when a worker last took an event or finished a batch, in Unix nanoseconds.
+	progress atomic.Int64
+
+	queueDepth metric.Int64UpDownCounter
+	latency    metric.Float64Histogram
+	coalesced  metric.Int64Counter
+	written    metric.Int64Counter
+}
+
+func newWorkerPool(workers, queueSize int, window time.Duration,
+	handle func(ctx context.Context, events []kubeview.Event)) *workerPool {
+	meter := otel.Meter("kube-kg/internal/processor")
+	queueDepth, err := meter.Int64UpDownCounter("processor.events.queued",
+		metric.WithDescription("Events waiting in the worker queues"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.events.queued", "err", err)
+	}
+	latency, err := meter.Float64Histogram("processor.batch.duration",
+		metric.WithDescription("Time taken to apply a batch of events to the graph"),
+		metric.WithUnit("s"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.batch.duration", "err", err)
+	}
+	coalesced, err := meter.Int64Counter("processor.events.coalesced",
+		metric.WithDescription("Events superseded by a later event for the same resource before being written"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.events.coalesced", "err", err)
+	}
+	written, err := meter.Int64Counter("processor.events.written",
+		metric.WithDescription("Events written to the graph after coalescing"))
+	if err != nil {
+		slog.Warn("failed to create metric", "name", "processor.events.written", "err", err)
+	}
+
+	pool := &workerPool{
+		handle:     handle,
+		window:     window,
+		queues:     make([]chan kubeview.Event, workers),
+		done:       make(chan struct{}),
+		queueDepth: queueDepth,
+		latency:    latency,
+		coalesced:  coalesced,
+		written:    written,
+	}
+	for i := range pool.queues {
+		pool.queues[i] = make(chan kubeview.Event, queueSize)
+	}
+	return pool
+}
+
+// run dispatches events from eventChan to the workers until ctx is cancelled or eventChan
+// is closed. Events already received are still applied, with a context that is not
+// cancelled, so that shutdown does not drop them.
+func (wp *workerPool) run(ctx context.Context, eventChan <-chan kubeview.Event) {
+	drainCtx := context.WithoutCancel(ctx)
+	wp.markProgress()
+	for _, queue := range wp.queues {
+		wp.wg.Add(1)
+		go wp.work(drainCtx, queue)
+	}
+
+	go func() {
+		defer func() {
+			for _, queue := range wp.queues {
+				close(queue)
+			}
+			wp.wg.Wait()
+			close(wp.done)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				wp.drain(drainCtx, eventChan)
+				return
+			case event, ok := <-eventChan:
+				if !ok {
+					return
+				}
+				wp.enqueue(drainCtx, event)
+			}
+		}
+	}()
+}
+
+// depth returns the number of events waiting in the worker queues.
+func (wp *workerPool) depth() int {
+	var depth int
+	for _, queue := range wp.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+func (wp *workerPool) markProgress() {
+	wp.progress.Store(time.Now().UnixNano())
+}
+
+// stalledFor returns how long events have been waiting without any worker making progress,
+// or zero if no event is waiting.
+func (wp *workerPool) stalledFor() time.Duration {
+	if wp.depth() == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, wp.progress.Load()))
+}
+
+// drain enqueues the events already buffered in eventChan without waiting for more.
+func (wp *workerPool) drain(ctx context.Context, eventChan <-chan kubeview.Event) {
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			wp.enqueue(ctx, event)
+		default:
+			return
+		}
+	}
+}
+
+// enqueue hands event to the worker owning its UID, blocking while that worker's queue is full.
+func (wp *workerPool) enqueue(ctx context.Context, event kubeview.Event) {
+	wp.queueDepth.Add(ctx, 1)
+	wp.queues[wp.shard(event.Object.Metadata.UID)] <- event
+}
+
+func (wp *workerPool) shard(uid string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(uid))
+	return int(h.Sum32() % uint32(len(wp.queues)))
+}
+
+func (wp *workerPool) work(ctx context.Context, queue <-chan kubeview.Event) {
+	defer wp.wg.Done()
+
+	pending := newCoalescer()
+	var flushTimer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-queue:
+			if !ok {
+				wp.flush(ctx, pending)
+				return
+			}
+			wp.queueDepth.Add(ctx, -1)
+			wp.markProgress()
+			pending.add(event)
+			if wp.window <= 0 {
+				wp.flush(ctx, pending)
+			} else if flushTimer == nil {
+				flushTimer = time.After(wp.window)
+			}
+		case <-flushTimer:
+			flushTimer = nil
+			wp.flush(ctx, pending)
+		}
+	}
+}
+
+// flush applies the pending events as one batch.
+func (wp *workerPool) flush(ctx context.Context, pending *coalescer) {
+	events, coalesced := pending.take()
+	if coalesced > 0 {
+		wp.coalesced.Add(ctx, int64(coalesced))
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	start := time.Now()
+	wp.handle(ctx, events)
+	wp.markProgress()
+	wp.latency.Record(ctx, time.Since(start).Seconds())
+	wp.written.Add(ctx, int64(len(events)))
+}
+
+// wait blocks until every received event has been applied or ctx is done.
+func (wp *workerPool) wait(ctx context.Context) error {
+	select {
+	case <-wp.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"kube-kg/internal/kubeview"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventFor(uid, resourceVersion string) kubeview.Event {
+	return kubeview.Event{Type: kubeview.EventTypeUpdate, Object: kubeview.KubernetesResource{
+		Kind:     "Pod",
+		Metadata: kubeview.ObjectMeta{Name: uid, UID: uid, ResourceVersion: resourceVersion},
+	}}
+}
+
+// recorder collects the resource versions handled for each UID and the size of each batch.
+type recorder struct {
+	mu       sync.Mutex
+	versions map[string][]string
+	batches  []int
+}
+
+func (r *recorder) handle(_ context.Context, events []kubeview.Event) {
+	// Give other workers the chance to interleave.
+	time.Sleep(time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(events))
+	for _, event := range events {
+		uid := event.Object.Metadata.UID
+		r.versions[uid] = append(r.versions[uid], event.Object.Metadata.ResourceVersion)
+	}
+}
+
+func TestWorkerPool_PreservesOrderPerUID(t *testing.T) {
+	rec := &recorder{versions: make(map[string][]string)}
+	pool := newWorkerPool(4, 2, 0, rec.handle)
+	eventChan := make(chan kubeview.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.run(ctx, eventChan)
+
+	uids := []string{"a", "b", "c", "d", "e", "f"}
+	var want []string
+	for v := 1; v <= 10; v++ {
+		want = append(want, fmt.Sprint(v))
+		for _, uid := range uids {
+			eventChan <- eventFor(uid, fmt.Sprint(v))
+		}
+	}
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	require.NoError(t, pool.wait(waitCtx))
+
+	for _, uid := range uids {
+		assert.Equal(t, want, rec.versions[uid], "uid %s", uid)
+	}
+}
+
+func TestWorkerPool_DrainsBufferedEventsOnShutdown(t *testing.T) {
+	rec := &recorder{versions: make(map[string][]string)}
+	pool := newWorkerPool(2, 1, 0, rec.handle)
+	eventChan := make(chan kubeview.Event, 20)
+	for i := range 20 {
+		eventChan <- eventFor(fmt.Sprintf("uid-%d", i), "1")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pool.run(ctx, eventChan)
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	require.NoError(t, pool.wait(waitCtx))
+
+	assert.Len(t, rec.versions, 20)
+}
+
+func TestWorkerPool_StopsWhenEventChannelCloses(t *testing.T) {
+	rec := &recorder{versions: make(map[string][]string)}
+	pool := newWorkerPool(2, 1, 0, rec.handle)
+	eventChan := make(chan kubeview.Event, 1)
+	eventChan <- eventFor("a", "1")
+	close(eventChan)
+	pool.run(context.Background(), eventChan)
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	require.NoError(t, pool.wait(waitCtx))
+
+	assert.Equal(t, []string{"1"}, rec.versions["a"])
+}
+
+func TestWorkerPool_CoalescesWithinWindow(t *testing.T) {
+	rec := &recorder{versions: make(map[string][]string)}
+	pool := newWorkerPool(1, 100, time.Hour, rec.handle)
+	eventChan := make(chan kubeview.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.run(ctx, eventChan)
+
+	for v := 1; v <= 10; v++ {
+		eventChan <- eventFor("a", fmt.Sprint(v))
+		eventChan <- eventFor("b", fmt.Sprint(v))
+	}
+	// Shutting down flushes the pending batch without waiting for the window.
+	cancel()
+
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	require.NoError(t, pool.wait(waitCtx))
+
+	assert.Equal(t, []int{2}, rec.batches)
+	assert.Equal(t, []string{"10"}, rec.versions["a"])
+	assert.Equal(t, []string{"10"}, rec.versions["b"])
+}
+
+func TestWorkerPool_ReportsStall(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(1, 10, 0, func(context.Context, []kubeview.Event) { <-release })
+	eventChan := make(chan kubeview.Event)
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.run(ctx, eventChan)
+
+	assert.Zero(t, pool.stalledFor(), "an idle pool is not stalled")
+
+	// The first event blocks the worker, the second waits behind it.
+	eventChan <- eventFor("a", "1")
+	eventChan <- eventFor("a", "2")
+	require.Eventually(t, func() bool { return pool.stalledFor() > 20*time.Millisecond }, time.Second, 5*time.Millisecond)
+
+	close(release)
+	require.Eventually(t, func() bool { return pool.stalledFor() == 0 }, time.Second, 5*time.Millisecond)
+	cancel()
+}